@@ -0,0 +1,270 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Each record of the daily files stores the hash of the previous record, so editing or deleting
+// a record breaks the link of the next one. The chain continues across the daily files of a sink.
+
+// hashRecord returns the hash stored by the record that follows the line
+func hashRecord(line []byte) string {
+	sum := sha256.Sum256(line)
+	return hex.EncodeToString(sum[:])
+}
+
+// encodeCSV returns the CSV line of the record without the line break
+func encodeCSV(record []string) ([]byte, error) {
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+	writer.Write(record)
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buffer.Bytes(), []byte("\n")), nil
+}
+
+// openLogFile opens a daily file for reading, decompressing it if needed
+func openLogFile(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(path, ".gz") {
+		return file, nil
+	}
+
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{reader, file}, nil
+}
+
+// isLogFile reports whether the file belongs to the daily files with the extension
+func isLogFile(path, extension string) bool {
+	return strings.HasSuffix(path, extension) || strings.HasSuffix(path, extension+".gz")
+}
+
+// lastHash returns the hash of the last record in the daily files with the extension,
+// or an empty string if there are none
+func (d dailyFiles) lastHash(extension string) (string, error) {
+	files, err := d.list()
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	for i := len(files) - 1; i >= 0; i-- {
+		if !isLogFile(files[i].path, extension) {
+			continue
+		}
+
+		var last []byte
+		err := readRecords(files[i].path, extension, func(line []byte, _ int) error {
+			last = line
+			return nil
+		})
+		if err != nil {
+			return "", fmt.Errorf("reading %s: %w", files[i].path, err)
+		}
+		if last != nil {
+			return hashRecord(last), nil
+		}
+	}
+	return "", nil
+}
+
+// readRecords calls the function with the line of each record of a daily file and its line number.
+// CSV records are encoded again, which gives the same bytes that were written.
+func readRecords(path, extension string, record func(line []byte, lineNumber int) error) error {
+	file, err := openLogFile(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if extension == ".csv" {
+		reader := csv.NewReader(file)
+		reader.FieldsPerRecord = -1
+		// Skip the header
+		if _, err := reader.Read(); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		for {
+			fields, err := reader.Read()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			line, err := encodeCSV(fields)
+			if err != nil {
+				return err
+			}
+			lineNumber, _ := reader.FieldPos(0)
+			if err := record(line, lineNumber); err != nil {
+				return err
+			}
+		}
+	}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		line := append([]byte{}, scanner.Bytes()...)
+		if err := record(line, lineNumber); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// BrokenLink is a record whose previous hash doesn't match the record before it
+type BrokenLink struct {
+	File     string
+	Line     int
+	Expected string
+	Found    string
+}
+
+// ChainReport is the result of verifying the chain of the daily files of a sink
+type ChainReport struct {
+	Extension string
+	Files     int
+	Records   int
+	// Records written before the chain existed, without previous hash
+	Unchained int
+	// First broken link, nil if the chain is intact
+	Broken *BrokenLink
+}
+
+// Verify walks the CSV and JSON Lines daily files of the directory from the oldest
+// and reports the first broken link of each chain. The first record found is trusted,
+// since the files before it may have been deleted by the retention policy.
+func Verify(dir string) ([]ChainReport, error) {
+	files, err := dailyFiles{dir: dir, location: time.UTC}.list()
+	if err != nil {
+		return nil, err
+	}
+
+	var reports []ChainReport
+	for _, extension := range []string{".csv", ".jsonl"} {
+		report := ChainReport{Extension: extension}
+		previous := ""
+		for _, file := range files {
+			if !isLogFile(file.path, extension) {
+				continue
+			}
+			report.Files++
+
+			var header []string
+			if extension == ".csv" {
+				header, err = readCSVHeader(file.path)
+				if err != nil {
+					return nil, fmt.Errorf("reading %s: %w", file.path, err)
+				}
+			}
+
+			err := readRecords(file.path, extension, func(line []byte, lineNumber int) error {
+				report.Records++
+				prevHash, chained, err := recordPrevHash(line, extension, header)
+				if err != nil {
+					report.Broken = &BrokenLink{File: file.path, Line: lineNumber, Expected: previous, Found: "invalid record: " + err.Error()}
+					return errBroken
+				}
+
+				if !chained {
+					report.Unchained++
+				} else if previous != "" && prevHash != previous {
+					report.Broken = &BrokenLink{File: file.path, Line: lineNumber, Expected: previous, Found: prevHash}
+					return errBroken
+				}
+				previous = hashRecord(line)
+				return nil
+			})
+			if err == errBroken {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("reading %s: %w", file.path, err)
+			}
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+var errBroken = errors.New("broken link")
+
+// recordPrevHash returns the previous hash stored in the record and whether it has one
+func recordPrevHash(line []byte, extension string, header []string) (string, bool, error) {
+	if extension == ".csv" {
+		// The hash is the last column of the versions that have it
+		if len(header) == 0 || header[len(header)-1] != "PrevHash" {
+			return "", false, nil
+		}
+		fields, err := csv.NewReader(bytes.NewReader(line)).Read()
+		if err != nil {
+			return "", false, err
+		}
+		if len(fields) != len(header) {
+			return "", false, fmt.Errorf("expected %d columns, found %d", len(header), len(fields))
+		}
+		return fields[len(fields)-1], true, nil
+	}
+
+	var record struct {
+		PrevHash *string `json:"prev_hash"`
+	}
+	if err := json.Unmarshal(line, &record); err != nil {
+		return "", false, err
+	}
+	if record.PrevHash == nil {
+		return "", false, nil
+	}
+	return *record.PrevHash, true, nil
+}
+
+// readCSVHeader returns the header of a daily CSV file, compressed or not
+func readCSVHeader(path string) ([]string, error) {
+	file, err := openLogFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	header, err := csv.NewReader(file).Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	return header, err
+}
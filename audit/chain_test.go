@@ -0,0 +1,168 @@
+package audit
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// chainEvents are written on two days, so the chain continues from one file to the next
+var chainEvents = []Event{
+	{Type: "VOTE", GuildID: "100", Initiator: "bob", Target: "alice", Votes: 1, Time: time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)},
+	{Type: "VOTE", GuildID: "100", Initiator: "carol", Target: "alice", Votes: 2, Time: time.Date(2025, 3, 1, 10, 1, 0, 0, time.UTC)},
+	{Type: "MUTE", GuildID: "100", Initiator: "System", Target: "alice", Votes: 2, Time: time.Date(2025, 3, 1, 10, 1, 0, 0, time.UTC)},
+	{Type: "UNMUTE", GuildID: "100", Initiator: "System", Target: "alice", Time: time.Date(2025, 3, 2, 10, 0, 0, 0, time.UTC)},
+	{Type: "VOTE", GuildID: "100", Initiator: "alice", Target: "bob", Votes: 1, Time: time.Date(2025, 3, 2, 11, 0, 0, 0, time.UTC)},
+}
+
+// writeChain writes the events with the sinks of the extension and returns the directory
+func writeChain(t *testing.T, extension string) string {
+	t.Helper()
+	dir := t.TempDir()
+	var sink Sink = NewJSONLSink(dir, 0644, time.UTC)
+	if extension == ".csv" {
+		sink = NewCSVSink(dir, 0, 0644, time.UTC)
+	}
+	for _, event := range chainEvents {
+		if err := sink.Write(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// verifyReport returns the report of the extension for the directory
+func verifyReport(t *testing.T, dir, extension string) ChainReport {
+	t.Helper()
+	reports, err := Verify(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, report := range reports {
+		if report.Extension == extension {
+			return report
+		}
+	}
+	t.Fatalf("no report for %s", extension)
+	return ChainReport{}
+}
+
+// editLines replaces the lines of a file with the result of the function
+func editLines(t *testing.T, path string, edit func(lines []string) []string) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if err := os.WriteFile(path, []byte(strings.Join(edit(lines), "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifyChain(t *testing.T) {
+	for _, extension := range []string{".csv", ".jsonl"} {
+		// The CSV files start with the header
+		first := 0
+		if extension == ".csv" {
+			first = 1
+		}
+
+		t.Run(extension, func(t *testing.T) {
+			dir := writeChain(t, extension)
+			report := verifyReport(t, dir, extension)
+			if report.Broken != nil || report.Files != 2 || report.Records != len(chainEvents) || report.Unchained != 0 {
+				t.Errorf("report = %+v, want an intact chain of 2 files", report)
+			}
+		})
+
+		t.Run(extension+" tampered", func(t *testing.T) {
+			dir := writeChain(t, extension)
+			path := filepath.Join(dir, "2025-03-01"+extension)
+			editLines(t, path, func(lines []string) []string {
+				lines[first+1] = strings.Replace(lines[first+1], "carol", "mallory", 1)
+				return lines
+			})
+
+			report := verifyReport(t, dir, extension)
+			if report.Broken == nil || report.Broken.File != path || report.Broken.Line != first+3 {
+				t.Errorf("report = %+v, want the link of line %d broken", report, first+3)
+			}
+		})
+
+		t.Run(extension+" removed", func(t *testing.T) {
+			dir := writeChain(t, extension)
+			path := filepath.Join(dir, "2025-03-02"+extension)
+			editLines(t, path, func(lines []string) []string {
+				return append(lines[:first], lines[first+1:]...)
+			})
+
+			// The first record of the second day no longer follows the last of the first day
+			report := verifyReport(t, dir, extension)
+			if report.Broken == nil || report.Broken.File != path || report.Broken.Line != first+1 {
+				t.Errorf("report = %+v, want the link of line %d broken", report, first+1)
+			}
+		})
+	}
+}
+
+// readCSVRecords returns the header and records of a CSV file
+func readCSVRecords(t *testing.T, path string) [][]string {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
+
+func TestCSVAppendKeepsHeaderVersion(t *testing.T) {
+	for version, header := range map[int][]string{1: HeaderV1, 2: HeaderV2} {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "2025-03-01.csv")
+		line, err := encodeCSV(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, append(line, '\n'), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		sink := NewCSVSink(dir, 0, 0644, time.UTC)
+		for _, event := range chainEvents {
+			if err := sink.Write(event); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		records := readCSVRecords(t, path)
+		if len(records) != 4 {
+			t.Fatalf("version %d: %d records, want the header and 3 events", version, len(records))
+		}
+		for _, record := range records {
+			if len(record) != len(header) {
+				t.Errorf("version %d: record %v, want %d columns", version, record, len(header))
+			}
+		}
+
+		// The next day starts a file with the latest version
+		if next := readCSVRecords(t, filepath.Join(dir, "2025-03-02.csv")); strings.Join(next[0], ",") != strings.Join(HeaderV5, ",") {
+			t.Errorf("version %d: header of the next day = %v", version, next[0])
+		}
+
+		report := verifyReport(t, dir, ".csv")
+		if report.Broken != nil || report.Unchained != 3 || report.Records != len(chainEvents) {
+			t.Errorf("version %d: report = %+v, want 3 records without hash and an intact chain", version, report)
+		}
+	}
+}
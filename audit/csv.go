@@ -0,0 +1,150 @@
+package audit
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const timestampFormat = "2006-01-02 15:04:05"
+
+// Columns of the daily CSV files. Version 2 appends IDs and context to the version 1 columns,
+// so readers of version 1 can keep using the first six columns. Version 3 appends the hash of
+// the previous record. Version 4 adds the voters, the reason and how a mute was applied, which
+// the mute data needs to be rebuilt, before the hash. Version 5 also adds the option and values
+// of the configuration changes.
+var (
+	HeaderV1 = []string{"Timestamp", "ActionType", "Initiator", "Target", "CurrentVotes", "GuildID"}
+	HeaderV2 = append(append([]string{}, HeaderV1...),
+		"InitiatorID", "TargetID", "VoiceChannelID", "TextChannelID", "MessageID", "Threshold", "ExpiresAt")
+	HeaderV3 = append(append([]string{}, HeaderV2...), "PrevHash")
+	HeaderV4 = append(append([]string{}, HeaderV2...), "Voters", "Reason", "ManualMute", "TimedOut", "PrevHash")
+	HeaderV5 = append(append([]string{}, HeaderV4[:len(HeaderV4)-1]...), "Key", "OldValue", "NewValue", "PrevHash")
+
+	csvHeaders = map[int][]string{1: HeaderV1, 2: HeaderV2, 3: HeaderV3, 4: HeaderV4, 5: HeaderV5}
+)
+
+// Latest version of the CSV files, used by default
+const csvVersion = 5
+
+// CSVSink appends the events to a CSV file per day, named YYYY-MM-DD.csv
+type CSVSink struct {
+	files   dailyFiles
+	version int
+	// Hash of the last record written, loaded from the files on the first write
+	lastHash string
+	chained  bool
+}
+
+func NewCSVSink(dir string, version int, mode os.FileMode, location *time.Location) *CSVSink {
+	if _, exists := csvHeaders[version]; !exists {
+		version = csvVersion
+	}
+	return &CSVSink{files: dailyFiles{dir: dir, mode: mode, location: location}, version: version}
+}
+
+func (c *CSVSink) Write(event Event) error {
+	// Filename based on the date of the event
+	logFile := c.files.path(event.Time, ".csv")
+
+	if !c.chained {
+		lastHash, err := c.files.lastHash(".csv")
+		if err != nil {
+			return fmt.Errorf("loading the hash chain: %w", err)
+		}
+		c.lastHash = lastHash
+		c.chained = true
+	}
+
+	// Keep the version of the header of an existing file, new files use the configured version
+	version := c.version
+	fileExists := false
+	if header, err := readCSVHeader(logFile); err == nil && header != nil {
+		fileExists = true
+		version = csvHeaderVersion(header)
+	}
+
+	record := csvRecord(event.inLocation(c.files.location), version)
+	if version >= 3 {
+		record = append(record, c.lastHash)
+	}
+	line, err := encodeCSV(record)
+	if err != nil {
+		return fmt.Errorf("encoding log record: %w", err)
+	}
+
+	var data []byte
+	// Write header if the file is new
+	if !fileExists {
+		data, err = encodeCSV(csvHeaders[version])
+		if err != nil {
+			return fmt.Errorf("encoding log header: %w", err)
+		}
+		data = append(data, '\n')
+	}
+	data = append(append(data, line...), '\n')
+
+	file, err := c.files.open(logFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := file.Write(data); err != nil {
+		return fmt.Errorf("writing log record: %w", err)
+	}
+	c.lastHash = hashRecord(line)
+	return nil
+}
+
+func (c *CSVSink) Close() error {
+	return nil
+}
+
+// csvRecord returns the columns of the event for the header version
+func csvRecord(event Event, version int) []string {
+	record := []string{event.Time.Format(timestampFormat), event.Type, event.Initiator, event.Target,
+		fmt.Sprintf("%d", event.Votes), event.GuildID}
+	if version == 1 {
+		return record
+	}
+
+	record = append(record, event.InitiatorID, event.TargetID, event.VoiceChannelID, event.ChannelID,
+		event.MessageID, fmt.Sprintf("%d", event.Threshold), formatTime(event.ExpiresAt))
+	if version < 4 {
+		return record
+	}
+
+	record = append(record, strings.Join(event.Voters, " "), event.Reason, formatBool(event.ManualMute),
+		formatBool(event.TimedOut))
+	if version < 5 {
+		return record
+	}
+
+	return append(record, event.Key, event.OldValue, event.NewValue)
+}
+
+// csvHeaderVersion returns the version of the header of an existing file
+func csvHeaderVersion(header []string) int {
+	for version, columns := range csvHeaders {
+		if len(header) == len(columns) {
+			return version
+		}
+	}
+	return csvVersion
+}
+
+func formatBool(value bool) string {
+	if !value {
+		return ""
+	}
+	return "true"
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(timestampFormat)
+}
@@ -0,0 +1,59 @@
+package audit
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCSVRoundTrip(t *testing.T) {
+	// Fields of the round trip event kept by each version
+	v1 := Event{Time: roundTripEvent.Time, Type: roundTripEvent.Type, GuildID: roundTripEvent.GuildID,
+		Initiator: roundTripEvent.Initiator, Target: roundTripEvent.Target, Votes: roundTripEvent.Votes}
+	v2 := roundTripEvent
+	v2.Voters, v2.Reason, v2.ManualMute, v2.TimedOut = nil, "", false, false
+	v2.Key, v2.OldValue, v2.NewValue = "", "", ""
+	v4 := roundTripEvent
+	v4.Key, v4.OldValue, v4.NewValue = "", "", ""
+
+	for version, want := range map[int]Event{1: v1, 2: v2, 3: v2, 4: v4, 5: roundTripEvent} {
+		dir := t.TempDir()
+		sink := NewCSVSink(dir, version, 0644, time.UTC)
+		if err := sink.Write(roundTripEvent); err != nil {
+			t.Fatal(err)
+		}
+
+		events, err := ReadCSV(dir, time.Time{}, time.Time{}, time.UTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != 1 {
+			t.Fatalf("version %d: %d events", version, len(events))
+		}
+		if !reflect.DeepEqual(events[0], want) {
+			t.Errorf("version %d: read %+v, want %+v", version, events[0], want)
+		}
+	}
+}
+
+func TestCSVTimeZone(t *testing.T) {
+	madrid, err := time.LoadLocation("Europe/Madrid")
+	if err != nil {
+		t.Skip(err)
+	}
+	dir := t.TempDir()
+	sink := NewCSVSink(dir, 0, 0644, madrid)
+	// 23:30 UTC is already the next day in Madrid
+	event := Event{Type: "VOTE", GuildID: "100", Time: time.Date(2025, 3, 1, 23, 30, 0, 0, time.UTC)}
+	if err := sink.Write(event); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := ReadCSV(dir, time.Date(2025, 3, 2, 0, 0, 0, 0, madrid), time.Time{}, madrid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || !events[0].Time.Equal(event.Time) {
+		t.Errorf("events = %+v, want the vote in the file of March 2", events)
+	}
+}
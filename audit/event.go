@@ -0,0 +1,42 @@
+// Package audit records the moderation events of the bot in one or more sinks:
+// daily CSV files, daily JSON Lines files, stdout or an HTTP webhook.
+package audit
+
+import "time"
+
+// Event is a moderation action. New fields can be added without breaking
+// the sinks, which write the fields they know about.
+type Event struct {
+	Time           time.Time `json:"time"`
+	Type           string    `json:"type"`
+	GuildID        string    `json:"guild_id"`
+	ChannelID      string    `json:"channel_id,omitempty"` // Text channel of the command
+	VoiceChannelID string    `json:"voice_channel_id,omitempty"`
+	MessageID      string    `json:"message_id,omitempty"` // Message or interaction that triggered the event
+	InitiatorID    string    `json:"initiator_id,omitempty"`
+	Initiator      string    `json:"initiator"`
+	TargetID       string    `json:"target_id,omitempty"`
+	Target         string    `json:"target"`
+	Votes          int       `json:"votes"`
+	Threshold      int       `json:"threshold,omitempty"`
+	Voters         []string  `json:"voters,omitempty"`
+	ExpiresAt      time.Time `json:"expires_at,omitempty"`
+	Reason         string    `json:"reason,omitempty"`
+	// The target of a mute already had a server mute from a moderator, which the bot leaves in place
+	ManualMute bool `json:"manual_mute,omitempty"`
+	// The target of a mute was also timed out until the mute ends
+	TimedOut bool `json:"timed_out,omitempty"`
+	// Option changed by a CONFIG event and its values
+	Key      string `json:"key,omitempty"`
+	OldValue string `json:"old_value,omitempty"`
+	NewValue string `json:"new_value,omitempty"`
+}
+
+// inLocation returns the event with its times in the time zone
+func (e Event) inLocation(location *time.Location) Event {
+	e.Time = e.Time.In(location)
+	if !e.ExpiresAt.IsZero() {
+		e.ExpiresAt = e.ExpiresAt.In(location)
+	}
+	return e
+}
@@ -0,0 +1,41 @@
+package audit
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const dateFormat = "2006-01-02"
+
+// dailyFiles names and creates the daily files of the sinks
+type dailyFiles struct {
+	dir      string
+	mode     os.FileMode
+	location *time.Location
+}
+
+// path returns the file of the day of t, in the configured time zone
+func (d dailyFiles) path(t time.Time, extension string) string {
+	return filepath.Join(d.dir, t.In(d.location).Format(dateFormat)+extension)
+}
+
+// open opens the file in append mode, creating it and the directory if needed
+func (d dailyFiles) open(path string) (*os.File, error) {
+	err := os.MkdirAll(d.dir, 0755)
+	if err != nil {
+		return nil, fmt.Errorf("creating logs directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, d.mode)
+	if err != nil {
+		return nil, fmt.Errorf("opening log file: %w", err)
+	}
+
+	// The umask may have changed the mode of a new file
+	if info, err := file.Stat(); err == nil && info.Size() == 0 && info.Mode().Perm() != d.mode {
+		file.Chmod(d.mode)
+	}
+	return file, nil
+}
@@ -0,0 +1,227 @@
+package audit
+
+import (
+	"compress/gzip"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RetentionPolicy limits the space used by the daily files. Zero values disable each rule.
+type RetentionPolicy struct {
+	// Days a daily file is kept after its day
+	RetentionDays int
+	// Days after which a daily file is compressed with gzip, at least 1 so the current file is never compressed
+	CompressAfterDays int
+	// Maximum size of all the daily files in bytes. The oldest files are deleted first.
+	MaxTotalSize int64
+	// Time between cleanups, one hour by default
+	Interval time.Duration
+}
+
+func (p RetentionPolicy) enabled() bool {
+	return p.RetentionDays > 0 || p.CompressAfterDays > 0 || p.MaxTotalSize > 0
+}
+
+type janitor struct {
+	done    chan struct{}
+	stopped sync.WaitGroup
+	once    sync.Once
+}
+
+func (j *janitor) stop() {
+	j.once.Do(func() {
+		close(j.done)
+	})
+	j.stopped.Wait()
+}
+
+// StartJanitor enforces the retention policy on the daily files in the background
+// until the logger is closed. It does nothing if no rule is enabled.
+func (l *Logger) StartJanitor(policy RetentionPolicy) {
+	if !policy.enabled() || l.janitor != nil {
+		return
+	}
+	if policy.Interval <= 0 {
+		policy.Interval = time.Hour
+	}
+
+	j := &janitor{done: make(chan struct{})}
+	l.janitor = j
+	j.stopped.Add(1)
+	go func() {
+		defer j.stopped.Done()
+
+		ticker := time.NewTicker(policy.Interval)
+		defer ticker.Stop()
+		for {
+			l.Cleanup(policy, time.Now())
+			select {
+			case <-ticker.C:
+			case <-j.done:
+				return
+			}
+		}
+	}()
+}
+
+// logFile is a daily file of the logs directory
+type logFile struct {
+	path string
+	day  time.Time
+	size int64
+}
+
+// Cleanup applies the retention policy to the daily files once
+func (l *Logger) Cleanup(policy RetentionPolicy, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	files, err := l.files.list()
+	if err != nil {
+		if !os.IsNotExist(err) {
+			slog.Error("Error listing log files", "dir", l.files.dir, "error", err)
+		}
+		return
+	}
+
+	today := l.files.day(now)
+	var kept []logFile
+	for _, file := range files {
+		age := daysBetween(file.day, today)
+
+		if policy.RetentionDays > 0 && age > policy.RetentionDays {
+			if err := os.Remove(file.path); err != nil {
+				slog.Error("Error deleting old log file", "file", file.path, "error", err)
+			} else {
+				slog.Info("Deleted old log file", "file", file.path, "retention_days", policy.RetentionDays)
+			}
+			continue
+		}
+
+		if policy.CompressAfterDays > 0 && age >= policy.CompressAfterDays && !strings.HasSuffix(file.path, ".gz") {
+			compressed, err := l.files.compress(file.path)
+			if err != nil {
+				slog.Error("Error compressing log file", "file", file.path, "error", err)
+			} else {
+				file.path = compressed
+				if info, err := os.Stat(compressed); err == nil {
+					file.size = info.Size()
+				}
+			}
+		}
+		kept = append(kept, file)
+	}
+
+	if policy.MaxTotalSize <= 0 {
+		return
+	}
+
+	var total int64
+	for _, file := range kept {
+		total += file.size
+	}
+	// The files are sorted from the oldest, the files of today are never deleted
+	for _, file := range kept {
+		if total <= policy.MaxTotalSize || !file.day.Before(today) {
+			break
+		}
+		if err := os.Remove(file.path); err != nil {
+			slog.Error("Error deleting log file", "file", file.path, "error", err)
+			continue
+		}
+		total -= file.size
+		slog.Info("Deleted log file, the logs exceed the maximum size", "file", file.path, "max_bytes", policy.MaxTotalSize)
+	}
+}
+
+// day returns the start of the day of t in the time zone of the files
+func (d dailyFiles) day(t time.Time) time.Time {
+	t = t.In(d.location)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, d.location)
+}
+
+// list returns the daily files, compressed or not, from the oldest
+func (d dailyFiles) list() ([]logFile, error) {
+	entries, err := os.ReadDir(d.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []logFile
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || len(name) < len(dateFormat) || strings.HasSuffix(name, ".tmp") {
+			continue
+		}
+		day, err := time.ParseInLocation(dateFormat, name[:len(dateFormat)], d.location)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, logFile{path: filepath.Join(d.dir, name), day: day, size: info.Size()})
+	}
+
+	sort.Slice(files, func(a, b int) bool {
+		if !files[a].day.Equal(files[b].day) {
+			return files[a].day.Before(files[b].day)
+		}
+		return files[a].path < files[b].path
+	})
+	return files, nil
+}
+
+// compress replaces the file with a gzip copy and returns its path
+func (d dailyFiles) compress(path string) (string, error) {
+	compressed := path + ".gz"
+	if _, err := os.Stat(compressed); err == nil {
+		// Compressed by a previous cleanup that couldn't remove the original
+		return compressed, os.Remove(path)
+	}
+
+	source, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer source.Close()
+
+	temporary := compressed + ".tmp"
+	target, err := os.OpenFile(temporary, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, d.mode)
+	if err != nil {
+		return "", err
+	}
+
+	writer := gzip.NewWriter(target)
+	writer.Name = filepath.Base(path)
+	_, err = io.Copy(writer, source)
+	if err == nil {
+		err = writer.Close()
+	}
+	if closeErr := target.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(temporary)
+		return "", err
+	}
+
+	if err := os.Rename(temporary, compressed); err != nil {
+		os.Remove(temporary)
+		return "", err
+	}
+	return compressed, os.Remove(path)
+}
+
+// daysBetween returns the calendar days from one day to another
+func daysBetween(from, to time.Time) int {
+	return int(math.Round(to.Sub(from).Hours() / 24))
+}
@@ -0,0 +1,154 @@
+package audit
+
+import (
+	"compress/gzip"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The tests don't print the logs of the package
+func TestMain(m *testing.M) {
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	os.Exit(m.Run())
+}
+
+// Noon of the day the tests run the cleanup
+var cleanupTime = time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+
+// writeDailyFiles creates the daily files with the given size and returns a logger of the directory
+func writeDailyFiles(t *testing.T, sizes map[string]int) (*Logger, string) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, size := range sizes {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(strings.Repeat("x", size)), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	logger, err := New(nil, Options{Dir: dir, Location: time.UTC})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return logger, dir
+}
+
+// dirFiles returns the names of the files of the directory
+func dirFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+func assertFiles(t *testing.T, dir string, want ...string) {
+	t.Helper()
+	if got := dirFiles(t, dir); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("files = %v, want %v", got, want)
+	}
+}
+
+func TestCleanupRetention(t *testing.T) {
+	logger, dir := writeDailyFiles(t, map[string]int{
+		"2025-03-02.csv":      10,
+		"2025-03-02.jsonl.gz": 10,
+		"2025-03-03.csv":      10,
+		"2025-03-10.csv":      10,
+		"notes.txt":           10,
+	})
+
+	logger.Cleanup(RetentionPolicy{RetentionDays: 7}, cleanupTime)
+
+	// The files 8 days old are deleted, compressed or not, other files are left alone
+	assertFiles(t, dir, "2025-03-03.csv", "2025-03-10.csv", "notes.txt")
+}
+
+func TestCleanupCompressesOldDays(t *testing.T) {
+	logger, dir := writeDailyFiles(t, map[string]int{
+		"2025-03-06.csv":   100,
+		"2025-03-07.jsonl": 100,
+		"2025-03-09.csv":   100,
+		"2025-03-10.csv":   100,
+	})
+
+	logger.Cleanup(RetentionPolicy{CompressAfterDays: 3}, cleanupTime)
+
+	assertFiles(t, dir, "2025-03-06.csv.gz", "2025-03-07.jsonl.gz", "2025-03-09.csv", "2025-03-10.csv")
+
+	file, err := openLogFile(filepath.Join(dir, "2025-03-06.csv.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	content, err := io.ReadAll(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != strings.Repeat("x", 100) {
+		t.Errorf("decompressed file = %q", content)
+	}
+}
+
+func TestCleanupCompressedFileName(t *testing.T) {
+	logger, dir := writeDailyFiles(t, map[string]int{"2025-03-01.csv": 10})
+
+	logger.Cleanup(RetentionPolicy{CompressAfterDays: 1}, cleanupTime)
+
+	file, err := os.Open(filepath.Join(dir, "2025-03-01.csv.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reader.Name != "2025-03-01.csv" {
+		t.Errorf("name in the gzip header = %q", reader.Name)
+	}
+}
+
+func TestCleanupMaxSizeDeletesOldestFirst(t *testing.T) {
+	logger, dir := writeDailyFiles(t, map[string]int{
+		"2025-03-07.csv":   100,
+		"2025-03-08.csv":   100,
+		"2025-03-08.jsonl": 100,
+		"2025-03-09.csv":   100,
+		"2025-03-10.csv":   100,
+	})
+
+	logger.Cleanup(RetentionPolicy{MaxTotalSize: 250}, cleanupTime)
+
+	assertFiles(t, dir, "2025-03-09.csv", "2025-03-10.csv")
+}
+
+func TestCleanupKeepsToday(t *testing.T) {
+	logger, dir := writeDailyFiles(t, map[string]int{
+		"2025-03-09.csv":   100,
+		"2025-03-10.csv":   1000,
+		"2025-03-10.jsonl": 1000,
+	})
+
+	logger.Cleanup(RetentionPolicy{RetentionDays: 1, CompressAfterDays: 1, MaxTotalSize: 100}, cleanupTime)
+
+	// Yesterday is compressed and then deleted for the size, today is over the size but never touched
+	assertFiles(t, dir, "2025-03-10.csv", "2025-03-10.jsonl")
+}
+
+func TestCleanupWithoutDirectory(t *testing.T) {
+	logger, err := New(nil, Options{Dir: filepath.Join(t.TempDir(), "missing"), Location: time.UTC})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger.Cleanup(RetentionPolicy{RetentionDays: 1}, cleanupTime)
+}
@@ -0,0 +1,90 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// JSONLSink appends the events as JSON objects, one per line, to a file per day named YYYY-MM-DD.jsonl
+type JSONLSink struct {
+	files dailyFiles
+	// Hash of the last record written, loaded from the files on the first write
+	lastHash string
+	chained  bool
+}
+
+// chainedEvent is a record of the JSON Lines files
+type chainedEvent struct {
+	Event
+	PrevHash string `json:"prev_hash"`
+}
+
+func NewJSONLSink(dir string, mode os.FileMode, location *time.Location) *JSONLSink {
+	return &JSONLSink{files: dailyFiles{dir: dir, mode: mode, location: location}}
+}
+
+func (j *JSONLSink) Write(event Event) error {
+	if !j.chained {
+		lastHash, err := j.files.lastHash(".jsonl")
+		if err != nil {
+			return fmt.Errorf("loading the hash chain: %w", err)
+		}
+		j.lastHash = lastHash
+		j.chained = true
+	}
+
+	line, err := json.Marshal(chainedEvent{Event: event.inLocation(j.files.location), PrevHash: j.lastHash})
+	if err != nil {
+		return fmt.Errorf("serializing event: %w", err)
+	}
+
+	file, err := j.files.open(j.files.path(event.Time, ".jsonl"))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	j.lastHash = hashRecord(line)
+	return nil
+}
+
+func (j *JSONLSink) Close() error {
+	return nil
+}
+
+// StdoutSink writes the events as JSON Lines to the standard output
+type StdoutSink struct {
+	mu     sync.Mutex
+	writer io.Writer
+}
+
+func NewStdoutSink() *StdoutSink {
+	return &StdoutSink{writer: os.Stdout}
+}
+
+func (s *StdoutSink) Write(event Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return writeJSONLine(s.writer, event)
+}
+
+func (s *StdoutSink) Close() error {
+	return nil
+}
+
+func writeJSONLine(writer io.Writer, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("serializing event: %w", err)
+	}
+
+	_, err = writer.Write(append(data, '\n'))
+	return err
+}
@@ -0,0 +1,198 @@
+package audit
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Read returns the events of the daily CSV and JSON Lines files of the directory in chronological
+// order, for the days between from and to, both included. Events written to both formats are returned
+// once, from the JSON Lines files, which have every field. The CSV files also give the events written
+// before the JSON Lines files were enabled, or while only the CSV files were.
+func Read(dir string, from, to time.Time, location *time.Location) ([]Event, error) {
+	events, err := ReadJSONL(dir, from, to, location)
+	if err != nil {
+		return nil, err
+	}
+	csvEvents, err := ReadCSV(dir, from, to, location)
+	if err != nil {
+		return nil, err
+	}
+
+	// The CSV timestamps only have seconds, and version 1 has no user IDs
+	type eventKey struct {
+		time                             int64
+		kind, guildID, initiator, target string
+	}
+	key := func(event Event) eventKey {
+		return eventKey{event.Time.Unix(), event.Type, event.GuildID, event.Initiator, event.Target}
+	}
+	written := make(map[eventKey]int)
+	for _, event := range events {
+		written[key(event)]++
+	}
+	for _, event := range csvEvents {
+		if written[key(event)] > 0 {
+			written[key(event)]--
+			continue
+		}
+		events = append(events, event)
+	}
+
+	sort.SliceStable(events, func(a, b int) bool { return events[a].Time.Before(events[b].Time) })
+	return events, nil
+}
+
+// ReadCSV returns the events of the daily CSV files of the directory, compressed or not,
+// for the days between from and to, both included. Zero times don't limit the range.
+// Timestamps are read in the time zone the files were written in.
+func ReadCSV(dir string, from, to time.Time, location *time.Location) ([]Event, error) {
+	files, err := dailyFiles{dir: dir, location: location}.list()
+	if err != nil {
+		return nil, err
+	}
+
+	var events []Event
+	for _, file := range files {
+		if !isLogFile(file.path, ".csv") {
+			continue
+		}
+		if !from.IsZero() && file.day.Before(dayStart(from, location)) {
+			continue
+		}
+		if !to.IsZero() && file.day.After(to) {
+			continue
+		}
+
+		fileEvents, err := readCSVFile(file.path, location)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", file.path, err)
+		}
+		events = append(events, fileEvents...)
+	}
+	return events, nil
+}
+
+func dayStart(t time.Time, location *time.Location) time.Time {
+	return dailyFiles{location: location}.day(t)
+}
+
+func readCSVFile(path string, location *time.Location) ([]Event, error) {
+	file, err := openLogFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Position of each column, so any version of the header can be read
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[name] = i
+	}
+
+	var events []Event
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return events, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		field := func(name string) string {
+			if i, exists := columns[name]; exists && i < len(record) {
+				return record[i]
+			}
+			return ""
+		}
+
+		event := Event{
+			Type:           field("ActionType"),
+			GuildID:        field("GuildID"),
+			ChannelID:      field("TextChannelID"),
+			VoiceChannelID: field("VoiceChannelID"),
+			MessageID:      field("MessageID"),
+			InitiatorID:    field("InitiatorID"),
+			Initiator:      field("Initiator"),
+			TargetID:       field("TargetID"),
+			Target:         field("Target"),
+		}
+		event.Time, err = time.ParseInLocation(timestampFormat, field("Timestamp"), location)
+		if err != nil {
+			line, _ := reader.FieldPos(0)
+			return nil, fmt.Errorf("line %d: invalid timestamp %q", line, field("Timestamp"))
+		}
+		if expiresAt := field("ExpiresAt"); expiresAt != "" {
+			event.ExpiresAt, _ = time.ParseInLocation(timestampFormat, expiresAt, location)
+		}
+		event.Votes, _ = strconv.Atoi(strings.TrimSpace(field("CurrentVotes")))
+		event.Threshold, _ = strconv.Atoi(strings.TrimSpace(field("Threshold")))
+		if voters := field("Voters"); voters != "" {
+			event.Voters = strings.Fields(voters)
+		}
+		event.Reason = field("Reason")
+		event.ManualMute, _ = strconv.ParseBool(field("ManualMute"))
+		event.TimedOut, _ = strconv.ParseBool(field("TimedOut"))
+		event.Key = field("Key")
+		event.OldValue = field("OldValue")
+		event.NewValue = field("NewValue")
+		events = append(events, event)
+	}
+}
+
+// CSVRecord returns the columns of the event in the current version of the CSV files, without the hash
+func CSVRecord(event Event) []string {
+	return csvRecord(event, 2)
+}
+
+// ReadJSONL returns the events of the daily JSON Lines files of the directory, compressed or not,
+// for the days between from and to, both included. Zero times don't limit the range.
+func ReadJSONL(dir string, from, to time.Time, location *time.Location) ([]Event, error) {
+	files, err := dailyFiles{dir: dir, location: location}.list()
+	if err != nil {
+		return nil, err
+	}
+
+	var events []Event
+	for _, file := range files {
+		if !isLogFile(file.path, ".jsonl") {
+			continue
+		}
+		if !from.IsZero() && file.day.Before(dayStart(from, location)) {
+			continue
+		}
+		if !to.IsZero() && file.day.After(to) {
+			continue
+		}
+
+		err := readRecords(file.path, ".jsonl", func(line []byte, lineNumber int) error {
+			var event Event
+			if err := json.Unmarshal(line, &event); err != nil {
+				return fmt.Errorf("line %d: %w", lineNumber, err)
+			}
+			events = append(events, event)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", file.path, err)
+		}
+	}
+	return events, nil
+}
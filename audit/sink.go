@@ -0,0 +1,147 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// Sink receives every audit event
+type Sink interface {
+	Write(event Event) error
+	// Close flushes pending events and releases the sink
+	Close() error
+}
+
+// Options configures the sinks created by New
+type Options struct {
+	// Directory of the daily files
+	Dir string
+	// Permissions of new daily files, 0644 by default
+	FileMode os.FileMode
+	// Time zone of the names and timestamps of the daily files, the local time by default
+	Location *time.Location
+	// Version of the header of new CSV files
+	CSVVersion int
+	// URL that receives the events of the webhook sink
+	WebhookURL string
+	// Called with the errors of the webhook sink, the events it drops or fails to send.
+	// They don't fail Log, since they depend on another service.
+	OnWebhookError func(err error)
+}
+
+// Logger sends each event to all its sinks
+type Logger struct {
+	// Held while writing, so the janitor doesn't move a file that is being written
+	mu      sync.Mutex
+	sinks   []Sink
+	files   dailyFiles
+	janitor *janitor
+	// See Options.OnWebhookError
+	onWebhookError func(err error)
+}
+
+// New creates a logger with the sinks named in the configuration: csv, jsonl, stdout and webhook
+func New(names []string, options Options) (*Logger, error) {
+	logger := NewLogger()
+	if options.Dir != "" {
+		logger.files.dir = options.Dir
+	}
+	if options.FileMode != 0 {
+		logger.files.mode = options.FileMode
+	}
+	if options.Location != nil {
+		logger.files.location = options.Location
+	}
+	logger.onWebhookError = options.OnWebhookError
+
+	files := logger.files
+	for _, name := range names {
+		switch name {
+		case "csv":
+			logger.sinks = append(logger.sinks, NewCSVSink(files.dir, options.CSVVersion, files.mode, files.location))
+		case "jsonl":
+			logger.sinks = append(logger.sinks, NewJSONLSink(files.dir, files.mode, files.location))
+		case "stdout":
+			logger.sinks = append(logger.sinks, NewStdoutSink())
+		case "webhook":
+			if options.WebhookURL == "" {
+				logger.Close()
+				return nil, errors.New("the webhook sink needs a webhook URL")
+			}
+			sink := NewWebhookSink(options.WebhookURL)
+			sink.onError = options.OnWebhookError
+			logger.sinks = append(logger.sinks, sink)
+		default:
+			logger.Close()
+			return nil, fmt.Errorf("unknown audit sink %q", name)
+		}
+	}
+	return logger, nil
+}
+
+// NewLogger creates a logger with the given sinks
+func NewLogger(sinks ...Sink) *Logger {
+	return &Logger{
+		sinks: sinks,
+		files: dailyFiles{dir: "logs", mode: 0644, location: time.Local},
+	}
+}
+
+// Log writes the event to every sink. A failing sink doesn't stop the others,
+// the errors are logged and returned together, except the ones of the webhook sink,
+// which are passed to Options.OnWebhookError.
+func (l *Logger) Log(event Event) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var errs []error
+	for _, sink := range l.sinks {
+		if err := sink.Write(event); err != nil {
+			slog.Error("Error writing event to audit sink", "sink", fmt.Sprintf("%T", sink), "type", event.Type,
+				"guild_id", event.GuildID, "error", err)
+			if _, webhook := sink.(*WebhookSink); webhook {
+				if l.onWebhookError != nil {
+					l.onWebhookError(err)
+				}
+				continue
+			}
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close stops the janitor and closes every sink
+func (l *Logger) Close() error {
+	return l.Shutdown(context.Background())
+}
+
+// Shutdown closes the logger like Close. The sinks that send the events in the background,
+// like the webhook, give up on the events not sent when the context ends.
+func (l *Logger) Shutdown(ctx context.Context) error {
+	if l.janitor != nil {
+		l.janitor.stop()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var errs []error
+	for _, sink := range l.sinks {
+		var err error
+		if background, ok := sink.(interface{ Shutdown(context.Context) error }); ok {
+			err = background.Shutdown(ctx)
+		} else {
+			err = sink.Close()
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
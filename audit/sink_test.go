@@ -0,0 +1,279 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// roundTripEvent has every field of an event
+var roundTripEvent = Event{
+	Time:           time.Date(2025, 3, 1, 10, 0, 5, 0, time.UTC),
+	Type:           "MUTE",
+	GuildID:        "100",
+	ChannelID:      "200",
+	VoiceChannelID: "300",
+	MessageID:      "400",
+	InitiatorID:    "1",
+	Initiator:      "bob",
+	TargetID:       "2",
+	Target:         "alice, \"the loud one\"",
+	Votes:          3,
+	Threshold:      3,
+	Voters:         []string{"1", "3", "4"},
+	ExpiresAt:      time.Date(2025, 3, 1, 10, 5, 5, 0, time.UTC),
+	Reason:         "spam,\nin two lines",
+	ManualMute:     true,
+	TimedOut:       true,
+	Key:            "votes_needed",
+	OldValue:       "3",
+	NewValue:       "5",
+}
+
+func TestJSONLRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	sink := NewJSONLSink(dir, 0644, time.UTC)
+	if err := sink.Write(roundTripEvent); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := ReadJSONL(dir, time.Time{}, time.Time{}, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || !reflect.DeepEqual(events[0], roundTripEvent) {
+		t.Errorf("events = %+v, want %+v", events, roundTripEvent)
+	}
+}
+
+func TestStdoutSink(t *testing.T) {
+	var output bytes.Buffer
+	sink := &StdoutSink{writer: &output}
+	if err := sink.Write(roundTripEvent); err != nil {
+		t.Fatal(err)
+	}
+
+	var event Event
+	if err := json.Unmarshal(output.Bytes(), &event); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(event, roundTripEvent) || !strings.HasSuffix(output.String(), "}\n") {
+		t.Errorf("output = %q", output.String())
+	}
+}
+
+// webhookServer records the events posted to it. Each request waits for release if it's not nil.
+type webhookServer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	events  []Event
+	release chan struct{}
+}
+
+func newWebhookServer(t *testing.T, release chan struct{}) *webhookServer {
+	server := &webhookServer{release: release}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event Event
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil || r.Header.Get("Content-Type") != "application/json" {
+			http.Error(w, "bad event", http.StatusBadRequest)
+			return
+		}
+		if server.release != nil {
+			<-server.release
+		}
+		server.mu.Lock()
+		server.events = append(server.events, event)
+		server.mu.Unlock()
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func (s *webhookServer) received() []Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Event{}, s.events...)
+}
+
+func TestWebhookSendsEvents(t *testing.T) {
+	server := newWebhookServer(t, nil)
+	sink := NewWebhookSink(server.URL)
+
+	for _, event := range chainEvents {
+		if err := sink.Write(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Close waits for the queued events, which are sent in order
+	if received := server.received(); !reflect.DeepEqual(received, chainEvents) {
+		t.Errorf("received %+v, want %+v", received, chainEvents)
+	}
+}
+
+func TestWebhookQueueFull(t *testing.T) {
+	release := make(chan struct{})
+	server := newWebhookServer(t, release)
+	sink := NewWebhookSink(server.URL)
+
+	// The first event may be taken by the sender, which waits for the server
+	accepted := 0
+	var err error
+	for i := 0; i <= webhookQueueSize+1; i++ {
+		if err = sink.Write(Event{Type: "VOTE", Votes: i}); err != nil {
+			break
+		}
+		accepted++
+	}
+	if err == nil || !strings.Contains(err.Error(), "queue is full") {
+		t.Fatalf("error = %v after %d events, want a full queue", err, accepted)
+	}
+	if accepted < webhookQueueSize {
+		t.Errorf("accepted %d events, want at least %d", accepted, webhookQueueSize)
+	}
+
+	close(release)
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if received := server.received(); len(received) != accepted {
+		t.Errorf("received %d events, want the %d accepted", len(received), accepted)
+	}
+	if err := sink.Write(Event{Type: "VOTE"}); err == nil {
+		t.Error("event accepted after Close")
+	}
+}
+
+func TestWebhookServerError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	sink := NewWebhookSink(server.URL)
+	err := sink.post(Event{Type: "VOTE"})
+	if err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("error = %v, want the status of the server", err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// failingSink fails every write
+type failingSink struct {
+	events []Event
+}
+
+func (f *failingSink) Write(event Event) error {
+	f.events = append(f.events, event)
+	return errors.New("disk full")
+}
+
+func (f *failingSink) Close() error {
+	return nil
+}
+
+func TestLoggerWritesEverySink(t *testing.T) {
+	failing := &failingSink{}
+	var output bytes.Buffer
+	logger := NewLogger(failing, &StdoutSink{writer: &output})
+
+	err := logger.Log(roundTripEvent)
+
+	if err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Errorf("error = %v, want the error of the failing sink", err)
+	}
+	if len(failing.events) != 1 || output.Len() == 0 {
+		t.Error("a failing sink stopped the others")
+	}
+}
+
+func TestNewUnknownSink(t *testing.T) {
+	if _, err := New([]string{"csv", "kafka"}, Options{Dir: t.TempDir()}); err == nil {
+		t.Error("unknown sink accepted")
+	}
+	if _, err := New([]string{"webhook"}, Options{Dir: t.TempDir()}); err == nil {
+		t.Error("webhook sink without URL accepted")
+	}
+}
+
+func TestWebhookShutdownDeadline(t *testing.T) {
+	release := make(chan struct{})
+	server := newWebhookServer(t, release)
+	// Runs before the server is closed
+	t.Cleanup(func() { close(release) })
+	sink := NewWebhookSink(server.URL)
+	for _, event := range chainEvents {
+		if err := sink.Write(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := sink.Shutdown(ctx)
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error = %v, want the deadline", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Shutdown took %v, the deadline was 50ms", elapsed)
+	}
+	// The request waiting for the server is cancelled and the sender stops
+	select {
+	case <-sink.done:
+	case <-time.After(time.Second):
+		t.Error("sender still running after the deadline")
+	}
+}
+
+func TestLoggerReportsWebhookErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	reported := make(chan error, 2)
+	logger, err := New([]string{"webhook"}, Options{WebhookURL: server.URL, OnWebhookError: func(err error) {
+		reported <- err
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The request fails in the background
+	if err := logger.Log(Event{Type: "VOTE"}); err != nil {
+		t.Errorf("Log = %v", err)
+	}
+	if err := logger.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// The sink is closed and drops the event
+	if err := logger.Log(Event{Type: "VOTE"}); err != nil {
+		t.Errorf("Log = %v, want the webhook error reported apart", err)
+	}
+
+	for _, want := range []string{"500", "closed"} {
+		select {
+		case err := <-reported:
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("reported %v, want %q", err, want)
+			}
+		default:
+			t.Errorf("error %q not reported", want)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Events waiting to be sent before new ones are dropped
+const webhookQueueSize = 1000
+
+// WebhookSink posts each event as JSON to an HTTP endpoint. Events are sent in the background
+// so a slow endpoint doesn't block the bot.
+type WebhookSink struct {
+	url    string
+	client *http.Client
+	queue  chan Event
+	done   chan struct{}
+	// Cancels the requests once Shutdown gives up on the queued events
+	ctx    context.Context
+	cancel context.CancelFunc
+	// Called with the errors of the requests, see Options.OnWebhookError
+	onError func(err error)
+
+	// Guards the queue against writes after Close, events can still be recorded during a shutdown
+	mu     sync.Mutex
+	closed bool
+}
+
+func NewWebhookSink(url string) *WebhookSink {
+	w := &WebhookSink{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+		queue:  make(chan Event, webhookQueueSize),
+		done:   make(chan struct{}),
+	}
+	w.ctx, w.cancel = context.WithCancel(context.Background())
+	go w.run()
+	return w
+}
+
+func (w *WebhookSink) Write(event Event) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return fmt.Errorf("webhook sink is closed, dropping %s event", event.Type)
+	}
+
+	select {
+	case w.queue <- event:
+		return nil
+	default:
+		return fmt.Errorf("webhook queue is full, dropping %s event", event.Type)
+	}
+}
+
+// Close sends the queued events and stops the sink
+func (w *WebhookSink) Close() error {
+	return w.Shutdown(context.Background())
+}
+
+// Shutdown sends the queued events and stops the sink, like Close, but gives up on the events
+// not sent when the context ends
+func (w *WebhookSink) Shutdown(ctx context.Context) error {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.queue)
+	}
+	w.mu.Unlock()
+
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		unsent := len(w.queue)
+		w.cancel()
+		return fmt.Errorf("webhook sink stopped with %d queued events not sent: %w", unsent, ctx.Err())
+	}
+}
+
+func (w *WebhookSink) run() {
+	defer close(w.done)
+	for event := range w.queue {
+		if w.ctx.Err() != nil {
+			return
+		}
+		if err := w.post(event); err != nil {
+			slog.Error("Error sending event to audit webhook", "type", event.Type, "guild_id", event.GuildID, "error", err)
+			if w.onError != nil {
+				w.onError(err)
+			}
+		}
+	}
+}
+
+func (w *WebhookSink) post(event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(w.ctx, http.MethodPost, w.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,301 @@
+// auditquery searches and summarizes the daily CSV and JSON Lines log files of DiscMuteBot.
+// Events written to both formats are counted once.
+//
+// Examples:
+//
+//	auditquery -target alice -action MUTE,FORCE_MUTE -last 30d
+//	auditquery -guild 123456789 -from 2025-03-01 -to 2025-03-31 -group-by action
+//	auditquery -initiator bob -format json
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+	// Time zones for -tz in images without tzdata
+	_ "time/tzdata"
+
+	"github.com/tu-usuario/DiscMuteBot/audit"
+)
+
+const dateFormat = "2006-01-02"
+
+// Fields accepted by -group-by
+var groupings = map[string]func(event audit.Event, location *time.Location) string{
+	"action":    func(event audit.Event, _ *time.Location) string { return event.Type },
+	"guild":     func(event audit.Event, _ *time.Location) string { return event.GuildID },
+	"initiator": func(event audit.Event, _ *time.Location) string { return userLabel(event.Initiator, event.InitiatorID) },
+	"target":    func(event audit.Event, _ *time.Location) string { return userLabel(event.Target, event.TargetID) },
+	"day": func(event audit.Event, location *time.Location) string {
+		return event.Time.In(location).Format(dateFormat)
+	},
+	"month": func(event audit.Event, location *time.Location) string {
+		return event.Time.In(location).Format("2006-01")
+	},
+}
+
+type filters struct {
+	from, to  time.Time
+	guild     string
+	actions   map[string]bool
+	initiator string
+	target    string
+}
+
+type summaryRow struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("auditquery", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	dir := flags.String("dir", "logs", "directory of the daily log files")
+	from := flags.String("from", "", "first day, YYYY-MM-DD")
+	to := flags.String("to", "", "last day, YYYY-MM-DD (included)")
+	last := flags.String("last", "", "period until now, like 24h, 7d or 30d (instead of -from)")
+	guild := flags.String("guild", "", "guild ID")
+	action := flags.String("action", "", "action types separated by commas, like MUTE,FORCE_MUTE")
+	initiator := flags.String("initiator", "", "username or user ID of the initiator")
+	target := flags.String("target", "", "username or user ID of the target")
+	format := flags.String("format", "table", "output format: table, json or csv")
+	groupBy := flags.String("group-by", "", "count the events by action, guild, initiator, target, day or month")
+	timezone := flags.String("tz", "", "time zone the files were written in (log_timezone of the bot), the local time by default")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	location := time.Local
+	if *timezone != "" {
+		var err error
+		location, err = time.LoadLocation(*timezone)
+		if err != nil {
+			fmt.Fprintf(stderr, "Invalid time zone %q: %v\n", *timezone, err)
+			return 2
+		}
+	}
+
+	f, err := parseFilters(*from, *to, *last, location, time.Now())
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	f.guild = *guild
+	f.initiator = strings.ToLower(*initiator)
+	f.target = strings.ToLower(*target)
+	if *action != "" {
+		f.actions = make(map[string]bool)
+		for _, name := range strings.Split(*action, ",") {
+			f.actions[strings.ToUpper(strings.TrimSpace(name))] = true
+		}
+	}
+
+	group, grouped := groupings[*groupBy]
+	if *groupBy != "" && !grouped {
+		fmt.Fprintf(stderr, "Invalid -group-by %q, use action, guild, initiator, target, day or month\n", *groupBy)
+		return 2
+	}
+	if *format != "table" && *format != "json" && *format != "csv" {
+		fmt.Fprintf(stderr, "Invalid -format %q, use table, json or csv\n", *format)
+		return 2
+	}
+
+	events, err := audit.Read(*dir, f.from, f.to, location)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error reading the log files: %v\n", err)
+		return 1
+	}
+
+	var matched []audit.Event
+	for _, event := range events {
+		if f.match(event) {
+			matched = append(matched, event)
+		}
+	}
+
+	if grouped {
+		err = writeSummary(stdout, *format, summarize(matched, group, location))
+	} else {
+		err = writeEvents(stdout, *format, matched, location)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "Error writing the results: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// parseFilters returns the date range of the flags
+func parseFilters(from, to, last string, location *time.Location, now time.Time) (filters, error) {
+	var f filters
+	var err error
+
+	if last != "" {
+		if from != "" {
+			return f, fmt.Errorf("use -from or -last, not both")
+		}
+		period, err := parsePeriod(last)
+		if err != nil {
+			return f, err
+		}
+		f.from = now.Add(-period)
+	}
+	if from != "" {
+		f.from, err = time.ParseInLocation(dateFormat, from, location)
+		if err != nil {
+			return f, fmt.Errorf("invalid -from %q, use YYYY-MM-DD", from)
+		}
+	}
+	if to != "" {
+		f.to, err = time.ParseInLocation(dateFormat, to, location)
+		if err != nil {
+			return f, fmt.Errorf("invalid -to %q, use YYYY-MM-DD", to)
+		}
+	}
+	if from != "" && to != "" && f.to.Before(f.from) {
+		return f, fmt.Errorf("-to is before -from")
+	}
+	return f, nil
+}
+
+// parsePeriod accepts Go durations and a number of days, like 30d
+func parsePeriod(value string) (time.Duration, error) {
+	if days, found := strings.CutSuffix(value, "d"); found {
+		n, err := strconv.Atoi(days)
+		if err == nil && n > 0 {
+			return time.Duration(n) * 24 * time.Hour, nil
+		}
+	} else if period, err := time.ParseDuration(value); err == nil && period > 0 {
+		return period, nil
+	}
+	return 0, fmt.Errorf("invalid -last %q, use a period like 24h, 7d or 30d", value)
+}
+
+func (f filters) match(event audit.Event) bool {
+	if !f.from.IsZero() && event.Time.Before(f.from) {
+		return false
+	}
+	// -to includes the whole day
+	if !f.to.IsZero() && !event.Time.Before(f.to.AddDate(0, 0, 1)) {
+		return false
+	}
+	if f.guild != "" && event.GuildID != f.guild {
+		return false
+	}
+	if f.actions != nil && !f.actions[event.Type] {
+		return false
+	}
+	if f.initiator != "" && !matchUser(f.initiator, event.Initiator, event.InitiatorID) {
+		return false
+	}
+	if f.target != "" && !matchUser(f.target, event.Target, event.TargetID) {
+		return false
+	}
+	return true
+}
+
+// matchUser compares the filter with the username, ignoring case, or the user ID
+func matchUser(filter, username, userID string) bool {
+	return strings.ToLower(username) == filter || (userID != "" && userID == filter)
+}
+
+func userLabel(username, userID string) string {
+	if userID == "" {
+		return username
+	}
+	return fmt.Sprintf("%s (%s)", username, userID)
+}
+
+// summarize counts the events of each group, from the largest
+func summarize(events []audit.Event, group func(audit.Event, *time.Location) string, location *time.Location) []summaryRow {
+	counts := make(map[string]int)
+	for _, event := range events {
+		counts[group(event, location)]++
+	}
+
+	var rows []summaryRow
+	for key, count := range counts {
+		rows = append(rows, summaryRow{Key: key, Count: count})
+	}
+	sort.Slice(rows, func(a, b int) bool {
+		if rows[a].Count != rows[b].Count {
+			return rows[a].Count > rows[b].Count
+		}
+		return rows[a].Key < rows[b].Key
+	})
+	return rows
+}
+
+func writeSummary(w io.Writer, format string, rows []summaryRow) error {
+	switch format {
+	case "json":
+		if rows == nil {
+			rows = []summaryRow{}
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(rows)
+	case "csv":
+		writer := csv.NewWriter(w)
+		writer.Write([]string{"Key", "Count"})
+		for _, row := range rows {
+			writer.Write([]string{row.Key, strconv.Itoa(row.Count)})
+		}
+		writer.Flush()
+		return writer.Error()
+	}
+
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "KEY\tCOUNT")
+	total := 0
+	for _, row := range rows {
+		fmt.Fprintf(table, "%s\t%d\n", row.Key, row.Count)
+		total += row.Count
+	}
+	fmt.Fprintf(table, "TOTAL\t%d\n", total)
+	return table.Flush()
+}
+
+func writeEvents(w io.Writer, format string, events []audit.Event, location *time.Location) error {
+	switch format {
+	case "json":
+		if events == nil {
+			events = []audit.Event{}
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(events)
+	case "csv":
+		writer := csv.NewWriter(w)
+		writer.Write(audit.HeaderV2)
+		for _, event := range events {
+			writer.Write(audit.CSVRecord(event))
+		}
+		writer.Flush()
+		return writer.Error()
+	}
+
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "TIME\tACTION\tINITIATOR\tTARGET\tVOTES\tGUILD")
+	for _, event := range events {
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%d\t%s\n", event.Time.In(location).Format("2006-01-02 15:04:05"),
+			event.Type, event.Initiator, event.Target, event.Votes, event.GuildID)
+	}
+	if err := table.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "%d events\n", len(events))
+	return err
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tu-usuario/DiscMuteBot/audit"
+)
+
+var testEvents = []audit.Event{
+	{Type: "VOTE", GuildID: "100", InitiatorID: "1", Initiator: "bob", TargetID: "2", Target: "alice",
+		Time: time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)},
+	{Type: "MUTE", GuildID: "100", Initiator: "System", TargetID: "2", Target: "alice",
+		Time: time.Date(2025, 3, 1, 10, 0, 1, 0, time.UTC)},
+	{Type: "FORCE_MUTE", GuildID: "200", InitiatorID: "3", Initiator: "carol", TargetID: "2", Target: "alice",
+		Time: time.Date(2025, 3, 2, 9, 30, 0, 0, time.UTC)},
+	{Type: "VOTE", GuildID: "100", InitiatorID: "2", Initiator: "alice", TargetID: "1", Target: "bob",
+		Time: time.Date(2025, 3, 3, 18, 0, 0, 0, time.UTC)},
+}
+
+// logDir writes the test events to a temporary directory with the sinks
+func logDir(t *testing.T, sinks ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	logger, err := audit.New(sinks, audit.Options{Dir: dir, Location: time.UTC})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, event := range testEvents {
+		if err := logger.Log(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := logger.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestRun(t *testing.T) {
+	dirs := map[string]string{
+		"csv":   logDir(t, "csv"),
+		"jsonl": logDir(t, "jsonl"),
+		"both":  logDir(t, "csv", "jsonl"),
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		code int
+		want []string
+		skip []string
+	}{
+		{
+			name: "all events",
+			want: []string{"VOTE", "MUTE", "FORCE_MUTE", "4 events"},
+		},
+		{
+			name: "target and action",
+			args: []string{"-target", "ALICE", "-action", "mute,force_mute"},
+			want: []string{"MUTE", "FORCE_MUTE", "2 events"},
+			skip: []string{"VOTE"},
+		},
+		{
+			name: "initiator ID",
+			args: []string{"-initiator", "3"},
+			want: []string{"carol", "1 events"},
+		},
+		{
+			name: "day range",
+			args: []string{"-from", "2025-03-02", "-to", "2025-03-02"},
+			want: []string{"FORCE_MUTE", "1 events"},
+			skip: []string{"VOTE"},
+		},
+		{
+			name: "group by guild",
+			args: []string{"-group-by", "guild", "-format", "csv"},
+			want: []string{"Key,Count", "100,3", "200,1"},
+		},
+		{
+			name: "invalid group",
+			args: []string{"-group-by", "weekday"},
+			code: 2,
+		},
+		{
+			name: "-from and -last",
+			args: []string{"-from", "2025-03-01", "-last", "7d"},
+			code: 2,
+		},
+	}
+
+	for format, dir := range dirs {
+		for _, test := range tests {
+			t.Run(format+"/"+test.name, func(t *testing.T) {
+				var stdout, stderr bytes.Buffer
+				args := append([]string{"-dir", dir, "-tz", "UTC"}, test.args...)
+
+				code := run(args, &stdout, &stderr)
+
+				if code != test.code {
+					t.Fatalf("exit code = %d, want %d: %s", code, test.code, stderr.String())
+				}
+				for _, want := range test.want {
+					if !strings.Contains(stdout.String(), want) {
+						t.Errorf("output doesn't have %q:\n%s", want, stdout.String())
+					}
+				}
+				for _, skip := range test.skip {
+					if strings.Contains(stdout.String(), skip+" ") {
+						t.Errorf("output has %q:\n%s", skip, stdout.String())
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestParsePeriod(t *testing.T) {
+	tests := map[string]time.Duration{"24h": 24 * time.Hour, "7d": 7 * 24 * time.Hour, "90m": 90 * time.Minute}
+	for value, want := range tests {
+		if got, err := parsePeriod(value); err != nil || got != want {
+			t.Errorf("parsePeriod(%q) = %v, %v, want %v", value, got, err, want)
+		}
+	}
+	for _, value := range []string{"", "0d", "-1h", "week"} {
+		if _, err := parsePeriod(value); err == nil {
+			t.Errorf("parsePeriod(%q) accepted", value)
+		}
+	}
+}
@@ -0,0 +1,352 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/tu-usuario/DiscMuteBot/audit"
+)
+
+// Retries of the mutes and unmutes that Discord failed to apply
+const (
+	ACTION_QUEUE_INTERVAL = 5 * time.Second
+	ACTION_BASE_BACKOFF   = 5 * time.Second
+	ACTION_MAX_BACKOFF    = 15 * time.Minute
+	// Mutes are abandoned after this many attempts, unmutes are retried until they succeed
+	// or Discord rejects them for good
+	ACTION_MAX_ATTEMPTS = 8
+	// Dead letters kept in the mute data
+	MAX_DEAD_LETTERS = 100
+)
+
+// PendingAction is a change of the voice mute of a member that Discord failed to apply
+type PendingAction struct {
+	ID          string    `json:"id"`
+	GuildID     string    `json:"guild_id"`
+	UserID      string    `json:"user_id"`
+	Mute        bool      `json:"mute"`
+	Attempts    int       `json:"attempts"`
+	CreatedAt   time.Time `json:"created_at"`
+	NextAttempt time.Time `json:"next_attempt"`
+	LastError   string    `json:"last_error"`
+}
+
+func (a PendingAction) operation() string {
+	if a.Mute {
+		return "mute"
+	}
+	return "unmute"
+}
+
+var (
+	// The change failed and was queued, the queue applies it later
+	errQueued = errors.New("mute change queued to retry")
+	// Discord rejected the unmute for good, it was moved to the dead letters
+	errDeadLettered = errors.New("unmute abandoned")
+)
+
+// muteChangeDeferred tells whether a failed mute change was taken over by the queue or the dead
+// letters, so the bot can update its state as if it was applied
+func muteChangeDeferred(err error) bool {
+	return errors.Is(err, errQueued) || errors.Is(err, errDeadLettered)
+}
+
+// pendingNote explains in the answer of a command that Discord didn't apply the change yet
+func pendingNote(err error) string {
+	switch {
+	case errors.Is(err, errQueued):
+		return "\n⏳ Discord didn't accept the change yet, the bot keeps retrying it."
+	case errors.Is(err, errDeadLettered):
+		return "\n⚠️ Discord rejected the unmute, see `!deadletters`."
+	}
+	return ""
+}
+
+func actionKey(guildID, userID string) string {
+	return guildID + ":" + userID
+}
+
+// guildMemberMute changes the voice mute of the member, counting the errors of the API.
+// Failures that can be retried are queued and errQueued is returned, the queue applies the change
+// later. Unmutes that can't be retried are dead-lettered and errDeadLettered is returned.
+func guildMemberMute(s Discord, guildID, userID string, mute bool) error {
+	action := PendingAction{GuildID: guildID, UserID: userID, Mute: mute}
+
+	expectMuteChange(guildID, userID, mute)
+	err := s.GuildMemberMute(guildID, userID, mute)
+	if err == nil {
+		// A newer change replaces the one waiting in the queue
+		if _, queued := muteData.Actions[actionKey(guildID, userID)]; queued {
+			delete(muteData.Actions, actionKey(guildID, userID))
+			saveMuteData()
+		}
+		return nil
+	}
+	muteAPIErrors.Inc(action.operation())
+
+	retry, wait := retryableMuteError(err, mute)
+	if !retry && mute {
+		return err
+	}
+
+	now := clock.Now()
+	muteData.LastActionID++
+	action.ID = strconv.Itoa(muteData.LastActionID)
+	action.Attempts = 1
+	action.CreatedAt = now
+	action.LastError = err.Error()
+
+	// The member must not stay muted by the bot, the moderators can retry the unmute from the dead letters
+	if !retry {
+		delete(muteData.Actions, actionKey(guildID, userID))
+		deadLetter(action)
+		return fmt.Errorf("%w: %v", errDeadLettered, err)
+	}
+
+	action.NextAttempt = now.Add(max(wait, ACTION_BASE_BACKOFF))
+	queueAction(action)
+
+	slog.Warn("Mute change failed, queued to retry", "guild_id", guildID, "user_id", userID,
+		"operation", action.operation(), "retry_at", action.NextAttempt, "error", err)
+	return errQueued
+}
+
+// retryableMuteError tells whether a failed mute change can be retried, and the time Discord asked
+// to wait if it was rate limited. Unmutes of members who aren't in a voice channel are retried when
+// they join one, a missing permission or a member who left the guild aren't.
+func retryableMuteError(err error, mute bool) (retry bool, wait time.Duration) {
+	var rateLimitErr *discordgo.RateLimitError
+	if errors.As(err, &rateLimitErr) {
+		return true, rateLimitErr.RetryAfter
+	}
+
+	var restErr *discordgo.RESTError
+	if !errors.As(err, &restErr) || restErr.Response == nil {
+		// Network errors
+		return true, 0
+	}
+
+	status := restErr.Response.StatusCode
+	if status == http.StatusTooManyRequests {
+		if seconds, err := strconv.ParseFloat(restErr.Response.Header.Get("Retry-After"), 64); err == nil {
+			wait = time.Duration(seconds * float64(time.Second))
+		}
+		return true, wait
+	}
+	if status >= 500 {
+		return true, 0
+	}
+	notInVoice := restErr.Message != nil && restErr.Message.Code == discordgo.ErrCodeTargetIsNotConnectedToVoice
+	return !mute && notInVoice, 0
+}
+
+// actionBackoff returns the wait before the next attempt, doubling after every failure
+func actionBackoff(attempts int) time.Duration {
+	backoff := ACTION_BASE_BACKOFF
+	for i := 1; i < attempts && backoff < ACTION_MAX_BACKOFF; i++ {
+		backoff *= 2
+	}
+	return min(backoff, ACTION_MAX_BACKOFF)
+}
+
+// queueAction stores the action, replacing the one queued for the same member
+func queueAction(action PendingAction) {
+	if muteData.Actions == nil {
+		muteData.Actions = make(map[string]PendingAction)
+	}
+	muteData.Actions[actionKey(action.GuildID, action.UserID)] = action
+	saveMuteData()
+}
+
+// startActionQueue retries the queued actions, including the ones stored by a previous process
+func startActionQueue(s Discord) {
+	if len(muteData.Actions) > 0 {
+		slog.Info("Resuming queued mute changes", "actions", len(muteData.Actions))
+	}
+
+	scheduleActionQueue(s)
+}
+
+func scheduleActionQueue(s Discord) {
+	clock.AfterFunc(ACTION_QUEUE_INTERVAL, func() {
+		// The next process retries the queue after a shutdown
+		if !beginWork() {
+			return
+		}
+		defer endWork()
+
+		processActions(s, clock.Now())
+		scheduleActionQueue(s)
+	})
+}
+
+// processActions runs the queued actions whose next attempt is due
+func processActions(s Discord, now time.Time) {
+	for _, action := range muteData.Actions {
+		if now.Before(action.NextAttempt) {
+			continue
+		}
+		runAction(s, action, now)
+	}
+}
+
+// retryMemberActions runs the action queued for the member right away, used when they join a
+// voice channel since Discord can't change the mute of members outside of them
+func retryMemberActions(s Discord, guildID, userID string) {
+	action, queued := muteData.Actions[actionKey(guildID, userID)]
+	if !queued {
+		return
+	}
+	runAction(s, action, clock.Now())
+}
+
+func runAction(s Discord, action PendingAction, now time.Time) {
+	key := actionKey(action.GuildID, action.UserID)
+
+	// The mute status changed since the action was queued
+	muteInfo, exists := muteData.MutedUsers[action.UserID]
+	muted := exists && muteInfo.IsGloballyMuted && muteInfo.GuildID == action.GuildID && now.Before(muteInfo.MuteExpiry)
+	if muted != action.Mute {
+		slog.Info("Dropping outdated mute change", "guild_id", action.GuildID, "user_id", action.UserID,
+			"operation", action.operation())
+		delete(muteData.Actions, key)
+		saveMuteData()
+		return
+	}
+
+	expectMuteChange(action.GuildID, action.UserID, action.Mute)
+	err := s.GuildMemberMute(action.GuildID, action.UserID, action.Mute)
+	if err == nil {
+		slog.Info("Queued mute change applied", "guild_id", action.GuildID, "user_id", action.UserID,
+			"operation", action.operation(), "attempts", action.Attempts+1)
+		delete(muteData.Actions, key)
+		saveMuteData()
+		return
+	}
+	muteAPIErrors.Inc(action.operation())
+
+	action.Attempts++
+	action.LastError = err.Error()
+	retry, wait := retryableMuteError(err, action.Mute)
+	if !retry || (action.Mute && action.Attempts >= ACTION_MAX_ATTEMPTS) {
+		delete(muteData.Actions, key)
+		deadLetter(action)
+		return
+	}
+
+	action.NextAttempt = now.Add(max(wait, actionBackoff(action.Attempts)))
+	muteData.Actions[key] = action
+	saveMuteData()
+	slog.Warn("Queued mute change failed again", "guild_id", action.GuildID, "user_id", action.UserID,
+		"operation", action.operation(), "attempts", action.Attempts, "retry_at", action.NextAttempt, "error", err)
+}
+
+// deadLetter gives up on the action and reports it to the moderators
+func deadLetter(action PendingAction) {
+	muteData.DeadLetters = append(muteData.DeadLetters, action)
+	if len(muteData.DeadLetters) > MAX_DEAD_LETTERS {
+		muteData.DeadLetters = muteData.DeadLetters[len(muteData.DeadLetters)-MAX_DEAD_LETTERS:]
+	}
+	saveMuteData()
+	muteActionsAbandoned.Inc(action.operation())
+
+	slog.Error("Giving up on mute change", "guild_id", action.GuildID, "user_id", action.UserID,
+		"operation", action.operation(), "attempts", action.Attempts, "error", action.LastError)
+
+	username := action.UserID
+	if session != nil {
+		if user, err := session.User(action.UserID); err == nil {
+			username = user.Username
+		}
+	}
+	recordEvent(audit.Event{
+		Type:     "DEAD_LETTER",
+		GuildID:  action.GuildID,
+		TargetID: action.UserID,
+		Target:   username,
+		Votes:    action.Attempts,
+		Reason:   fmt.Sprintf("%s failed: %s", action.operation(), action.LastError),
+	})
+}
+
+// handleDeadLetters processes !deadletters [retry <id>|clear]
+func handleDeadLetters(s Discord, m *discordgo.MessageCreate) {
+	args := strings.Fields(strings.TrimPrefix(m.Content, "!deadletters"))
+
+	switch {
+	case len(args) == 0:
+		s.ChannelMessageSend(m.ChannelID, deadLetterList(m.GuildID))
+	case args[0] == "retry" && len(args) == 2:
+		for i, action := range muteData.DeadLetters {
+			if action.ID != args[1] || action.GuildID != m.GuildID {
+				continue
+			}
+			muteData.DeadLetters = append(muteData.DeadLetters[:i], muteData.DeadLetters[i+1:]...)
+			action.Attempts = 0
+			action.NextAttempt = clock.Now()
+			queueAction(action)
+			s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("🔁 The %s of <@%s> will be retried.", action.operation(), action.UserID))
+			return
+		}
+		s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("❌ No dead letter with ID `%s`.", args[1]))
+	case args[0] == "clear" && len(args) == 1:
+		var kept []PendingAction
+		for _, action := range muteData.DeadLetters {
+			if action.GuildID != m.GuildID {
+				kept = append(kept, action)
+			}
+		}
+		removed := len(muteData.DeadLetters) - len(kept)
+		muteData.DeadLetters = kept
+		saveMuteData()
+		s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("🧹 Removed %d dead letters.", removed))
+	default:
+		s.ChannelMessageSend(m.ChannelID, "❌ Usage: `!deadletters`, `!deadletters retry <id>` or `!deadletters clear`")
+	}
+}
+
+// deadLetterList describes the queued and abandoned actions of the guild
+func deadLetterList(guildID string) string {
+	var queued []PendingAction
+	for _, action := range muteData.Actions {
+		if action.GuildID == guildID {
+			queued = append(queued, action)
+		}
+	}
+	sort.Slice(queued, func(a, b int) bool {
+		return queued[a].CreatedAt.Before(queued[b].CreatedAt)
+	})
+
+	var msg strings.Builder
+	if len(queued) > 0 {
+		msg.WriteString("🔁 **Mute changes waiting to be retried:**\n\n")
+		for _, action := range queued {
+			msg.WriteString(fmt.Sprintf("%s of <@%s> - %d attempts, next <t:%d:R>\n> %s\n", action.operation(), action.UserID,
+				action.Attempts, action.NextAttempt.Unix(), truncate(action.LastError, 200)))
+		}
+		msg.WriteString("\n")
+	}
+
+	msg.WriteString("📭 **Mute changes abandoned:**\n\n")
+	found := false
+	for _, action := range muteData.DeadLetters {
+		if action.GuildID != guildID {
+			continue
+		}
+		found = true
+		msg.WriteString(fmt.Sprintf("`%s` %s of <@%s> - %d attempts since %s\n> %s\n", action.ID, action.operation(),
+			action.UserID, action.Attempts, action.CreatedAt.Format("2006-01-02 15:04"), truncate(action.LastError, 200)))
+	}
+	if !found {
+		msg.WriteString("None.\n")
+	}
+	return truncate(msg.String(), 2000)
+}
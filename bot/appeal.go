@@ -0,0 +1,384 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/tu-usuario/DiscMuteBot/audit"
+)
+
+const (
+	APPEAL_PENDING  = "pending"
+	APPEAL_ACCEPTED = "accepted"
+	APPEAL_REJECTED = "rejected"
+
+	// Maximum length of the appeal text
+	APPEAL_MAX_LENGTH = 1000
+)
+
+type Appeal struct {
+	ID         string    `json:"id"`
+	GuildID    string    `json:"guild_id"`
+	UserID     string    `json:"user_id"`
+	Username   string    `json:"username"`
+	Text       string    `json:"text"`
+	Status     string    `json:"status"`
+	CreatedAt  time.Time `json:"created_at"`
+	ChannelID  string    `json:"channel_id"`
+	MessageID  string    `json:"message_id"`
+	ResolvedBy string    `json:"resolved_by,omitempty"`
+	ResolvedAt time.Time `json:"resolved_at,omitempty"`
+}
+
+// handleAppeal processes the !appeal <text> command
+func handleAppeal(s Discord, m *discordgo.MessageCreate) {
+	text := strings.TrimSpace(strings.TrimPrefix(m.Content, "!appeal"))
+	if text == "" {
+		s.ChannelMessageSend(m.ChannelID, "⚠️ You must explain your appeal. Example: `!appeal I was just joking, please unmute me`")
+		return
+	}
+
+	s.ChannelMessageSend(m.ChannelID, createAppeal(s, m.GuildID, m.Author, text))
+}
+
+// handleAppealOpen shows the appeal form when the button of the mute DM is pressed
+func handleAppealOpen(s Discord, i *discordgo.InteractionCreate, guildID string) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseModal,
+		Data: &discordgo.InteractionResponseData{
+			CustomID: "appeal_modal:" + guildID,
+			Title:    "Appeal your mute",
+			Components: []discordgo.MessageComponent{
+				discordgo.ActionsRow{
+					Components: []discordgo.MessageComponent{
+						discordgo.TextInput{
+							CustomID:  "appeal_text",
+							Label:     "Why should you be unmuted?",
+							Style:     discordgo.TextInputParagraph,
+							Required:  true,
+							MaxLength: APPEAL_MAX_LENGTH,
+						},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		slog.Error("Error showing appeal form", "guild_id", guildID, "user_id", interactionUser(i).ID, "error", err)
+	}
+}
+
+// handleAppealSubmit creates the appeal sent from the form
+func handleAppealSubmit(s Discord, i *discordgo.InteractionCreate, guildID string) {
+	text := ""
+	for _, row := range i.ModalSubmitData().Components {
+		actionsRow, ok := row.(*discordgo.ActionsRow)
+		if !ok {
+			continue
+		}
+		for _, component := range actionsRow.Components {
+			if input, ok := component.(*discordgo.TextInput); ok && input.CustomID == "appeal_text" {
+				text = strings.TrimSpace(input.Value)
+			}
+		}
+	}
+
+	if text == "" {
+		respondEphemeral(s, i, "⚠️ You must explain your appeal.")
+		return
+	}
+
+	respondEphemeral(s, i, createAppeal(s, guildID, interactionUser(i), text))
+}
+
+// createAppeal registers the appeal, posts it to the moderator channel and returns the answer for the user
+func createAppeal(s Discord, guildID string, user *discordgo.User, text string) string {
+	// Only muted users can appeal
+	muteInfo, exists := muteData.MutedUsers[user.ID]
+	if !exists || !muteInfo.IsGloballyMuted || clock.Now().After(muteInfo.MuteExpiry) ||
+		(muteInfo.GuildID != "" && muteInfo.GuildID != guildID) {
+		return "⚠️ You can only appeal while you are muted."
+	}
+
+	// Only one pending appeal per user
+	for _, appeal := range muteData.Appeals {
+		if appeal.GuildID == guildID && appeal.UserID == user.ID && appeal.Status == APPEAL_PENDING {
+			return fmt.Sprintf("⚠️ You already have a pending appeal (#%s). The moderators will review it soon.", appeal.ID)
+		}
+	}
+
+	channelID := guildSettings(guildID).AppealChannel
+	if channelID == "" {
+		return "❌ Appeals are not enabled in this server."
+	}
+
+	text = cutRunes(text, APPEAL_MAX_LENGTH)
+
+	appeal := Appeal{
+		ID:        nextID(&muteData.LastAppealID, muteData.Appeals),
+		GuildID:   guildID,
+		UserID:    user.ID,
+		Username:  user.Username,
+		Text:      text,
+		Status:    APPEAL_PENDING,
+		CreatedAt: clock.Now(),
+		ChannelID: channelID,
+	}
+
+	msg, err := s.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+		Embeds:     []*discordgo.MessageEmbed{appealEmbed(appeal)},
+		Components: appealButtons(appeal.ID),
+	})
+	if err != nil {
+		slog.Error("Error posting appeal", "guild_id", guildID, "channel_id", channelID, "user_id", user.ID, "error", err)
+		return "❌ Error sending your appeal to the moderators."
+	}
+	appeal.MessageID = msg.ID
+
+	muteData.Appeals[appeal.ID] = appeal
+	saveMuteData()
+
+	// Register appeal in log
+	recordEvent(audit.Event{
+		Type:        "APPEAL",
+		GuildID:     guildID,
+		ChannelID:   channelID,
+		MessageID:   appeal.MessageID,
+		InitiatorID: user.ID,
+		Initiator:   user.Username,
+		TargetID:    user.ID,
+		Target:      user.Username,
+		Reason:      text,
+	})
+
+	return fmt.Sprintf("📨 Your appeal (#%s) has been sent to the moderators.", appeal.ID)
+}
+
+// handleAppealResolve accepts or rejects an appeal from the buttons of the moderator channel
+func handleAppealResolve(s Discord, i *discordgo.InteractionCreate, appealID string, accepted bool) {
+	moderator := interactionUser(i)
+
+	if !requireInteractionPermission(s, i, "appeals") {
+		return
+	}
+
+	// The buttons only resolve appeals of the server they were pressed in
+	appeal, exists := muteData.Appeals[appealID]
+	if !exists || appeal.GuildID != i.GuildID {
+		respondEphemeral(s, i, "❌ Appeal not found.")
+		return
+	}
+	if appeal.Status != APPEAL_PENDING {
+		respondEphemeral(s, i, fmt.Sprintf("⚠️ Appeal #%s was already %s.", appeal.ID, appeal.Status))
+		return
+	}
+
+	if accepted {
+		// Same path as !clean
+		_, err := clearMute(s, appeal.GuildID, appeal.UserID)
+		if err != nil && !muteChangeDeferred(err) {
+			slog.Error("Error unmuting user", "guild_id", appeal.GuildID, "user_id", appeal.UserID, "error", err)
+			respondEphemeral(s, i, fmt.Sprintf("⚠️ Error unmuting %s", appeal.Username))
+			return
+		}
+		appeal.Status = APPEAL_ACCEPTED
+	} else {
+		appeal.Status = APPEAL_REJECTED
+	}
+
+	recordEvent(audit.Event{
+		Type:        "APPEAL_" + strings.ToUpper(appeal.Status),
+		GuildID:     appeal.GuildID,
+		ChannelID:   appeal.ChannelID,
+		MessageID:   appeal.MessageID,
+		InitiatorID: moderator.ID,
+		Initiator:   moderator.Username,
+		TargetID:    appeal.UserID,
+		Target:      appeal.Username,
+		Reason:      "Appeal #" + appeal.ID,
+	})
+
+	appeal.ResolvedBy = moderator.Username
+	appeal.ResolvedAt = clock.Now()
+	muteData.Appeals[appeal.ID] = appeal
+	saveMuteData()
+
+	// Replace the buttons with the resolution
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Embeds:     []*discordgo.MessageEmbed{appealEmbed(appeal)},
+			Components: []discordgo.MessageComponent{},
+		},
+	})
+	if err != nil {
+		slog.Error("Error updating appeal message", "guild_id", appeal.GuildID, "appeal_id", appeal.ID, "error", err)
+	}
+
+	// Let the user know the result
+	result := "❌ Your appeal #%s has been rejected by the moderators."
+	if accepted {
+		result = "🔊 Your appeal #%s has been accepted. You have been unmuted."
+	}
+	sendDM(s, appeal.UserID, fmt.Sprintf(result, appeal.ID))
+}
+
+// handleAppealList shows the pending appeals, or all of them with !appeals all
+func handleAppealList(s Discord, m *discordgo.MessageCreate) {
+	showAll := strings.TrimSpace(strings.TrimPrefix(m.Content, "!appeals")) == "all"
+
+	var appeals []Appeal
+	for _, appeal := range muteData.Appeals {
+		if appeal.GuildID == m.GuildID && (showAll || appeal.Status == APPEAL_PENDING) {
+			appeals = append(appeals, appeal)
+		}
+	}
+
+	if len(appeals) == 0 {
+		s.ChannelMessageSend(m.ChannelID, "📨 No appeals to show.")
+		return
+	}
+
+	sort.Slice(appeals, func(a, b int) bool {
+		return appeals[a].CreatedAt.Before(appeals[b].CreatedAt)
+	})
+
+	var msg strings.Builder
+	if showAll {
+		msg.WriteString("📨 **Appeals:**\n\n")
+	} else {
+		msg.WriteString("📨 **Pending appeals:**\n\n")
+	}
+
+	for _, appeal := range appeals {
+		text := appeal.Text
+		if cut := cutRunes(text, 80); cut != text {
+			text = cut + "..."
+		}
+		msg.WriteString(fmt.Sprintf("**#%s** %s - %s (%s)", appeal.ID, appeal.Username, appeal.Status,
+			appeal.CreatedAt.Format("2006-01-02 15:04")))
+		if appeal.ResolvedBy != "" {
+			msg.WriteString(fmt.Sprintf(" by %s", appeal.ResolvedBy))
+		}
+		msg.WriteString(fmt.Sprintf("\n> %s\n", text))
+	}
+
+	s.ChannelMessageSend(m.ChannelID, msg.String())
+}
+
+// notifyMuted sends a DM to the muted user with a button to appeal
+func notifyMuted(s Discord, guild *discordgo.Guild, target *discordgo.User, request muteRequest) {
+	settings := guildSettings(guild.ID)
+	content := fmt.Sprintf("🔇 You have been muted in the voice channels of **%s** for %d minutes.",
+		guild.Name, int(request.Duration.Minutes()))
+	if request.Reason != "" {
+		content += "\nReason: " + request.Reason
+	}
+
+	var components []discordgo.MessageComponent
+	if settings.AppealChannel != "" {
+		content += "\nIf you think this is unfair, you can appeal to the moderators."
+		components = []discordgo.MessageComponent{
+			discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					discordgo.Button{
+						Label:    "Appeal",
+						Style:    discordgo.PrimaryButton,
+						CustomID: "appeal_open:" + guild.ID,
+						Emoji:    discordgo.ComponentEmoji{Name: "📝"},
+					},
+				},
+			},
+		}
+	}
+
+	channel, err := s.UserChannelCreate(target.ID)
+	if err != nil {
+		slog.Warn("Error opening DM", "guild_id", guild.ID, "user_id", target.ID, "error", err)
+		return
+	}
+
+	_, err = s.ChannelMessageSendComplex(channel.ID, &discordgo.MessageSend{
+		Content:    content,
+		Components: components,
+	})
+	if err != nil {
+		slog.Warn("Error sending DM", "guild_id", guild.ID, "user_id", target.ID, "error", err)
+	}
+}
+
+// sendDM sends a direct message to the user, logging any error
+func sendDM(s Discord, userID, content string) {
+	channel, err := s.UserChannelCreate(userID)
+	if err != nil {
+		slog.Warn("Error opening DM", "user_id", userID, "error", err)
+		return
+	}
+
+	_, err = s.ChannelMessageSend(channel.ID, content)
+	if err != nil {
+		slog.Warn("Error sending DM", "user_id", userID, "error", err)
+	}
+}
+
+func appealEmbed(appeal Appeal) *discordgo.MessageEmbed {
+	embed := &discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("📨 Appeal #%s", appeal.ID),
+		Description: appeal.Text,
+		Color:       0xf1c40f,
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "User", Value: fmt.Sprintf("<@%s> (%s)", appeal.UserID, appeal.UserID), Inline: true},
+			{Name: "Status", Value: appeal.Status, Inline: true},
+		},
+		Timestamp: appeal.CreatedAt.Format(time.RFC3339),
+	}
+
+	switch appeal.Status {
+	case APPEAL_ACCEPTED:
+		embed.Color = 0x2ecc71
+	case APPEAL_REJECTED:
+		embed.Color = 0xe74c3c
+	}
+
+	if appeal.ResolvedBy != "" {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name: "Resolved by", Value: appeal.ResolvedBy, Inline: true,
+		})
+	}
+	return embed
+}
+
+func appealButtons(appealID string) []discordgo.MessageComponent {
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{
+					Label:    "Accept",
+					Style:    discordgo.SuccessButton,
+					CustomID: "appeal_accept:" + appealID,
+				},
+				discordgo.Button{
+					Label:    "Reject",
+					Style:    discordgo.DangerButton,
+					CustomID: "appeal_reject:" + appealID,
+				},
+			},
+		},
+	}
+}
+
+// cutRunes returns the first length characters of the text. Discord counts characters, not bytes,
+// and a multi-byte character must not be split.
+func cutRunes(text string, length int) string {
+	if utf8.RuneCountInString(text) <= length {
+		return text
+	}
+	runes := []rune(text)
+	return string(runes[:max(length, 0)])
+}
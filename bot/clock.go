@@ -0,0 +1,41 @@
+package main
+
+import (
+	"time"
+)
+
+// Clock tells the time and schedules the timers of the votes, mutes, juries and vote bans,
+// so the tests can move the time forward instead of waiting
+type Clock interface {
+	Now() time.Time
+	// AfterFunc calls f in its own goroutine after the duration, like time.AfterFunc
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a function scheduled by a Clock
+type Timer interface {
+	Stop() bool
+}
+
+// realClock is the Clock of the system
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+var clock Clock = realClock{}
+
+// timeUntil is time.Until on the clock of the bot
+func timeUntil(t time.Time) time.Duration {
+	return t.Sub(clock.Now())
+}
+
+// timeSince is time.Since on the clock of the bot
+func timeSince(t time.Time) time.Duration {
+	return clock.Now().Sub(t)
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock only moves when the test advances it, running the timers that are due on the way
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	clock   *fakeClock
+	at      time.Time
+	f       func()
+	stopped bool
+}
+
+// Clock of the test running, installed by setup
+var testClock *fakeClock
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	timer := &fakeTimer{clock: c, at: c.now.Add(d), f: f}
+	c.timers = append(c.timers, timer)
+	return timer
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	pending := !t.stopped
+	t.stopped = true
+	return pending
+}
+
+// Advance moves the clock forward, running the timers in order in the goroutine of the test.
+// Timers scheduled by them run too if they are due.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	end := c.now.Add(d)
+	for {
+		var next *fakeTimer
+		for _, timer := range c.timers {
+			if !timer.stopped && !timer.at.After(end) && (next == nil || timer.at.Before(next.at)) {
+				next = timer
+			}
+		}
+		if next == nil {
+			break
+		}
+		next.stopped = true
+		if next.at.After(c.now) {
+			c.now = next.at
+		}
+		c.mu.Unlock()
+		next.f()
+		c.mu.Lock()
+	}
+	c.now = end
+	c.mu.Unlock()
+}
+
+func TestVoteExpiry(t *testing.T) {
+	discord := setup(t)
+	setVotesNeeded(2)
+	target := discord.addMember("bob")
+	discord.joinVoice(target.ID, false)
+
+	messageCreate(discord, message(discord.addMember("alice"), "!mute @bob", target))
+	testClock.Advance(VOTE_DURATION + time.Second)
+	messageCreate(discord, message(discord.addMember("carol"), "!mute @bob", target))
+
+	muteInfo := muteData.MutedUsers[target.ID]
+	if muteInfo.IsGloballyMuted {
+		t.Fatal("muted with an expired vote")
+	}
+	if _, voted := muteInfo.MutedBy["alice"]; voted || len(muteInfo.MutedBy) != 1 {
+		t.Errorf("votes = %v, want only carol", muteInfo.MutedBy)
+	}
+}
+
+func TestVoteCountsUntilExpiry(t *testing.T) {
+	discord := setup(t)
+	setVotesNeeded(2)
+	target := discord.addMember("bob")
+	discord.joinVoice(target.ID, false)
+
+	messageCreate(discord, message(discord.addMember("alice"), "!mute @bob", target))
+	testClock.Advance(VOTE_DURATION - time.Second)
+	messageCreate(discord, message(discord.addMember("carol"), "!mute @bob", target))
+
+	if !muteData.MutedUsers[target.ID].IsGloballyMuted {
+		t.Error("vote expired before its time")
+	}
+}
+
+func TestMuteInfoTimeLeft(t *testing.T) {
+	discord := setup(t)
+	setVotesNeeded(2)
+	voter := discord.addMember("alice")
+	target := discord.addMember("bob")
+
+	messageCreate(discord, message(voter, "!mute @bob", target))
+	testClock.Advance(3*time.Minute + 20*time.Second)
+	messageCreate(discord, message(voter, "!muteinfo @bob", target))
+
+	want := "alice (expires in: " + (VOTE_DURATION - 3*time.Minute - 20*time.Second).String() + ")"
+	if msg := discord.lastMessage(testChannel); !strings.Contains(msg, want) {
+		t.Errorf("muteinfo = %q, want %q", msg, want)
+	}
+}
+
+func TestMuteExpiry(t *testing.T) {
+	discord := setup(t)
+	target := discord.addMember("bob")
+	discord.joinVoice(target.ID, false)
+	mute(t, discord, target)
+
+	testClock.Advance(MUTE_DURATION - time.Second)
+	if !muteData.MutedUsers[target.ID].IsGloballyMuted || !discord.serverMuted(target.ID) {
+		t.Fatal("unmuted before the mute expired")
+	}
+
+	testClock.Advance(time.Second)
+	if muteData.MutedUsers[target.ID].IsGloballyMuted || discord.serverMuted(target.ID) {
+		t.Fatal("still muted after the mute expired")
+	}
+	if types := eventTypes(); types[len(types)-1] != "UNMUTE" {
+		t.Errorf("events = %v, want UNMUTE last", types)
+	}
+}
+
+func TestMuteExtensionReschedulesUnmute(t *testing.T) {
+	discord := setup(t)
+	target := discord.addMember("bob")
+	discord.joinVoice(target.ID, false)
+	mute(t, discord, target)
+
+	// A longer mute replaces the first one before it ends
+	muteInfo := muteData.MutedUsers[target.ID]
+	muteInfo.MuteExpiry = muteInfo.MuteExpiry.Add(10 * time.Minute)
+	muteData.MutedUsers[target.ID] = muteInfo
+
+	testClock.Advance(MUTE_DURATION)
+	if !muteData.MutedUsers[target.ID].IsGloballyMuted || !discord.serverMuted(target.ID) {
+		t.Fatal("unmuted at the end of the replaced mute")
+	}
+
+	testClock.Advance(10 * time.Minute)
+	if muteData.MutedUsers[target.ID].IsGloballyMuted || discord.serverMuted(target.ID) {
+		t.Fatal("still muted after the longer mute expired")
+	}
+}
+
+func TestResumeTimers(t *testing.T) {
+	discord := setup(t)
+	expired := discord.addMember("bob")
+	discord.joinVoice(expired.ID, true)
+	pending := discord.addMember("carol")
+	discord.joinVoice(pending.ID, true)
+
+	// Mutes stored by a previous process, one expired while the bot was stopped
+	muteData.MutedUsers[expired.ID] = MuteInfo{GuildID: testGuild, MutedBy: make(map[string]time.Time),
+		IsGloballyMuted: true, MuteExpiry: clock.Now().Add(-time.Minute)}
+	muteData.MutedUsers[pending.ID] = MuteInfo{GuildID: testGuild, MutedBy: make(map[string]time.Time),
+		IsGloballyMuted: true, MuteExpiry: clock.Now().Add(time.Minute)}
+
+	resumeTimers(discord)
+	testClock.Advance(0)
+
+	if discord.serverMuted(expired.ID) {
+		t.Error("expired mute not lifted on start")
+	}
+	if !discord.serverMuted(pending.ID) {
+		t.Fatal("pending mute lifted on start")
+	}
+
+	testClock.Advance(time.Minute)
+	if discord.serverMuted(pending.ID) {
+		t.Error("pending mute not lifted when it expired")
+	}
+}
+
+func TestVoteBanExpiry(t *testing.T) {
+	discord := setup(t)
+	setVotesNeeded(3)
+	voter := discord.addMember("alice")
+	target := discord.addMember("bob")
+	muteData.VoteBans[testGuild] = map[string]VoteBan{
+		voter.ID: {UserID: voter.ID, CreatedAt: clock.Now(), Until: clock.Now().Add(time.Hour)},
+	}
+
+	messageCreate(discord, message(voter, "!mute @bob", target))
+	if msg := discord.lastMessage(testChannel); !strings.Contains(msg, "The ban ends in: 1h0m0s") {
+		t.Fatalf("unexpected answer: %q", msg)
+	}
+
+	testClock.Advance(time.Hour + time.Second)
+	messageCreate(discord, message(voter, "!mute @bob", target))
+	if _, voted := muteData.MutedUsers[target.ID].MutedBy[voter.ID]; !voted {
+		t.Errorf("vote rejected after the ban ended: %q", discord.lastMessage(testChannel))
+	}
+}
+
+func TestActionQueueRetriesOnSchedule(t *testing.T) {
+	discord := setup(t)
+	target := discord.addMember("bob")
+	discord.joinVoice(target.ID, true)
+	queueAction(PendingAction{ID: "1", GuildID: testGuild, UserID: target.ID, Attempts: 1,
+		NextAttempt: clock.Now().Add(time.Minute)})
+
+	resumeTimers(discord)
+	testClock.Advance(time.Minute - ACTION_QUEUE_INTERVAL)
+	if !discord.serverMuted(target.ID) {
+		t.Fatal("unmute retried before its time")
+	}
+
+	testClock.Advance(ACTION_QUEUE_INTERVAL)
+	if discord.serverMuted(target.ID) || len(muteData.Actions) != 0 {
+		t.Errorf("unmute not retried: %+v", muteData.Actions)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Discord is the part of the Discord API the bot uses. The handlers take it instead of a
+// *discordgo.Session so they can run against the in-memory fake of the tests.
+type Discord interface {
+	// REST API, with the same signatures as *discordgo.Session
+	ChannelMessageSend(channelID string, content string, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	ChannelMessageSendEmbed(channelID string, embed *discordgo.MessageEmbed, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	ChannelMessageEditComplex(m *discordgo.MessageEdit, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	InteractionRespond(interaction *discordgo.Interaction, resp *discordgo.InteractionResponse, options ...discordgo.RequestOption) error
+	FollowupMessageCreate(interaction *discordgo.Interaction, wait bool, data *discordgo.WebhookParams, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	ApplicationCommandCreate(appID string, guildID string, cmd *discordgo.ApplicationCommand, options ...discordgo.RequestOption) (*discordgo.ApplicationCommand, error)
+	Guild(guildID string, options ...discordgo.RequestOption) (*discordgo.Guild, error)
+	GuildMember(guildID, userID string, options ...discordgo.RequestOption) (*discordgo.Member, error)
+	GuildMemberMute(guildID string, userID string, mute bool, options ...discordgo.RequestOption) error
+	GuildMemberTimeout(guildID string, userID string, until *time.Time, options ...discordgo.RequestOption) error
+	GuildAuditLog(guildID, userID, beforeID string, actionType, limit int, options ...discordgo.RequestOption) (*discordgo.GuildAuditLog, error)
+	User(userID string, options ...discordgo.RequestOption) (*discordgo.User, error)
+	UserChannelCreate(recipientID string, options ...discordgo.RequestOption) (*discordgo.Channel, error)
+	UserChannelPermissions(userID, channelID string, options ...discordgo.RequestOption) (int64, error)
+
+	// Cache of the gateway events
+	BotUser() *discordgo.User
+	Intents() discordgo.Intent
+	StateGuilds() []*discordgo.Guild
+	StateGuild(guildID string) (*discordgo.Guild, error)
+	StateMember(guildID, userID string) (*discordgo.Member, error)
+	StateRole(guildID, roleID string) (*discordgo.Role, error)
+	StateVoiceState(guildID, userID string) (*discordgo.VoiceState, error)
+}
+
+// discordSession is the Discord implementation backed by a real session
+type discordSession struct {
+	*discordgo.Session
+}
+
+func (d discordSession) BotUser() *discordgo.User {
+	return d.State.User
+}
+
+func (d discordSession) Intents() discordgo.Intent {
+	return d.Identify.Intents
+}
+
+func (d discordSession) StateGuilds() []*discordgo.Guild {
+	d.State.RLock()
+	defer d.State.RUnlock()
+	return append([]*discordgo.Guild{}, d.State.Guilds...)
+}
+
+func (d discordSession) StateGuild(guildID string) (*discordgo.Guild, error) {
+	return d.State.Guild(guildID)
+}
+
+func (d discordSession) StateMember(guildID, userID string) (*discordgo.Member, error) {
+	return d.State.Member(guildID, userID)
+}
+
+func (d discordSession) StateRole(guildID, roleID string) (*discordgo.Role, error) {
+	return d.State.Role(guildID, roleID)
+}
+
+func (d discordSession) StateVoiceState(guildID, userID string) (*discordgo.VoiceState, error) {
+	return d.State.VoiceState(guildID, userID)
+}
+
+// setDiscordURL points discordgo to another Discord API, like "http://localhost:8081/".
+// The gateway is the one that API returns.
+func setDiscordURL(url string) {
+	discordgo.EndpointDiscord = strings.TrimSuffix(url, "/") + "/"
+	discordgo.EndpointAPI = discordgo.EndpointDiscord + "api/v" + discordgo.APIVersion + "/"
+	discordgo.EndpointGuilds = discordgo.EndpointAPI + "guilds/"
+	discordgo.EndpointChannels = discordgo.EndpointAPI + "channels/"
+	discordgo.EndpointUsers = discordgo.EndpointAPI + "users/"
+	discordgo.EndpointGateway = discordgo.EndpointAPI + "gateway"
+	discordgo.EndpointGatewayBot = discordgo.EndpointGateway + "/bot"
+	discordgo.EndpointWebhooks = discordgo.EndpointAPI + "webhooks/"
+	discordgo.EndpointApplications = discordgo.EndpointAPI + "applications"
+	discordgo.EndpointGuildCreate = discordgo.EndpointAPI + "guilds"
+}
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	testGuild   = "guild"
+	testChannel = "text"
+	testVoice   = "voice"
+	testOwner   = "owner"
+)
+
+// fakeDiscord is an in-memory Discord with one guild, where members can join the voice channel
+type fakeDiscord struct {
+	mu          sync.Mutex
+	bot         *discordgo.User
+	guild       *discordgo.Guild
+	users       map[string]*discordgo.User
+	permissions map[string]int64
+	auditLog    []*discordgo.AuditLogEntry
+	// Messages sent, by channel
+	messages map[string][]string
+	// Called before every mute change, the change fails if it returns an error
+	muteHook  func(guildID, userID string, mute bool) error
+	muteCalls int
+	// Timeouts of the members
+	timeouts map[string]time.Time
+}
+
+func newFakeDiscord() *fakeDiscord {
+	return &fakeDiscord{
+		bot:         &discordgo.User{ID: "bot", Username: "DiscMuteBot", Bot: true},
+		guild:       &discordgo.Guild{ID: testGuild, Name: "Test server", OwnerID: testOwner},
+		users:       make(map[string]*discordgo.User),
+		permissions: make(map[string]int64),
+		messages:    make(map[string][]string),
+		timeouts:    make(map[string]time.Time),
+	}
+}
+
+// addMember adds a member to the guild and returns their user
+func (f *fakeDiscord) addMember(id string, roles ...string) *discordgo.User {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	user := &discordgo.User{ID: id, Username: id}
+	f.users[id] = user
+	f.guild.Members = append(f.guild.Members, &discordgo.Member{GuildID: testGuild, User: user, Roles: roles})
+	return user
+}
+
+// joinVoice puts the member in the voice channel, with the server mute they had
+func (f *fakeDiscord) joinVoice(userID string, mute bool) *discordgo.VoiceState {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	vs := &discordgo.VoiceState{GuildID: testGuild, ChannelID: testVoice, UserID: userID, Mute: mute}
+	f.guild.VoiceStates = append(f.guild.VoiceStates, vs)
+	return vs
+}
+
+func (f *fakeDiscord) voiceState(userID string) *discordgo.VoiceState {
+	for _, vs := range f.guild.VoiceStates {
+		if vs.UserID == userID {
+			return vs
+		}
+	}
+	return nil
+}
+
+// serverMuted tells whether the member has a server mute in the voice channel
+func (f *fakeDiscord) serverMuted(userID string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	vs := f.voiceState(userID)
+	return vs != nil && vs.Mute
+}
+
+// sent returns the messages sent to the channel
+func (f *fakeDiscord) sent(channelID string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string{}, f.messages[channelID]...)
+}
+
+// lastMessage returns the last message sent to the channel
+func (f *fakeDiscord) lastMessage(channelID string) string {
+	messages := f.sent(channelID)
+	if len(messages) == 0 {
+		return ""
+	}
+	return messages[len(messages)-1]
+}
+
+func (f *fakeDiscord) send(channelID, content string) (*discordgo.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.messages[channelID] = append(f.messages[channelID], content)
+	return &discordgo.Message{ID: fmt.Sprintf("message-%d", len(f.messages[channelID])), ChannelID: channelID, Content: content}, nil
+}
+
+func (f *fakeDiscord) ChannelMessageSend(channelID string, content string, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	return f.send(channelID, content)
+}
+
+func (f *fakeDiscord) ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	content := data.Content
+	for _, embed := range data.Embeds {
+		content += embed.Title + "\n" + embed.Description
+	}
+	return f.send(channelID, content)
+}
+
+func (f *fakeDiscord) ChannelMessageSendEmbed(channelID string, embed *discordgo.MessageEmbed, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	content := embed.Title + "\n" + embed.Description
+	for _, field := range embed.Fields {
+		content += "\n" + field.Name + ": " + field.Value
+	}
+	return f.send(channelID, content)
+}
+
+func (f *fakeDiscord) ChannelMessageEditComplex(m *discordgo.MessageEdit, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	return &discordgo.Message{ID: m.ID, ChannelID: m.Channel}, nil
+}
+
+func (f *fakeDiscord) InteractionRespond(interaction *discordgo.Interaction, resp *discordgo.InteractionResponse, options ...discordgo.RequestOption) error {
+	if resp.Data != nil {
+		f.send(interaction.ChannelID, resp.Data.Content)
+	}
+	return nil
+}
+
+func (f *fakeDiscord) FollowupMessageCreate(interaction *discordgo.Interaction, wait bool, data *discordgo.WebhookParams, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	return f.send(interaction.ChannelID, data.Content)
+}
+
+func (f *fakeDiscord) ApplicationCommandCreate(appID string, guildID string, cmd *discordgo.ApplicationCommand, options ...discordgo.RequestOption) (*discordgo.ApplicationCommand, error) {
+	return cmd, nil
+}
+
+func (f *fakeDiscord) Guild(guildID string, options ...discordgo.RequestOption) (*discordgo.Guild, error) {
+	return f.StateGuild(guildID)
+}
+
+func (f *fakeDiscord) GuildMember(guildID, userID string, options ...discordgo.RequestOption) (*discordgo.Member, error) {
+	return f.StateMember(guildID, userID)
+}
+
+func (f *fakeDiscord) GuildMemberMute(guildID string, userID string, mute bool, options ...discordgo.RequestOption) error {
+	f.mu.Lock()
+	f.muteCalls++
+	hook := f.muteHook
+	f.mu.Unlock()
+	if hook != nil {
+		if err := hook(guildID, userID, mute); err != nil {
+			return err
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	vs := f.voiceState(userID)
+	if vs == nil {
+		return restError(http.StatusBadRequest, discordgo.ErrCodeTargetIsNotConnectedToVoice)
+	}
+	vs.Mute = mute
+	return nil
+}
+
+func (f *fakeDiscord) GuildMemberTimeout(guildID string, userID string, until *time.Time, options ...discordgo.RequestOption) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if until == nil {
+		delete(f.timeouts, userID)
+	} else {
+		f.timeouts[userID] = *until
+	}
+	return nil
+}
+
+// timedOut tells whether the member has a timeout
+func (f *fakeDiscord) timedOut(userID string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, exists := f.timeouts[userID]
+	return exists
+}
+
+func (f *fakeDiscord) GuildAuditLog(guildID, userID, beforeID string, actionType, limit int, options ...discordgo.RequestOption) (*discordgo.GuildAuditLog, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return &discordgo.GuildAuditLog{AuditLogEntries: append([]*discordgo.AuditLogEntry{}, f.auditLog...)}, nil
+}
+
+func (f *fakeDiscord) User(userID string, options ...discordgo.RequestOption) (*discordgo.User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	user, exists := f.users[userID]
+	if !exists {
+		return nil, restError(http.StatusNotFound, discordgo.ErrCodeUnknownUser)
+	}
+	return user, nil
+}
+
+func (f *fakeDiscord) UserChannelCreate(recipientID string, options ...discordgo.RequestOption) (*discordgo.Channel, error) {
+	return &discordgo.Channel{ID: "dm-" + recipientID, Type: discordgo.ChannelTypeDM}, nil
+}
+
+func (f *fakeDiscord) UserChannelPermissions(userID, channelID string, options ...discordgo.RequestOption) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.permissions[userID], nil
+}
+
+func (f *fakeDiscord) BotUser() *discordgo.User {
+	return f.bot
+}
+
+func (f *fakeDiscord) Intents() discordgo.Intent {
+	return discordgo.IntentsGuilds | discordgo.IntentsGuildVoiceStates
+}
+
+func (f *fakeDiscord) StateGuilds() []*discordgo.Guild {
+	return []*discordgo.Guild{f.guild}
+}
+
+func (f *fakeDiscord) StateGuild(guildID string) (*discordgo.Guild, error) {
+	if guildID != f.guild.ID {
+		return nil, discordgo.ErrStateNotFound
+	}
+	return f.guild, nil
+}
+
+func (f *fakeDiscord) StateMember(guildID, userID string) (*discordgo.Member, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, member := range f.guild.Members {
+		if member.User.ID == userID {
+			return member, nil
+		}
+	}
+	return nil, discordgo.ErrStateNotFound
+}
+
+func (f *fakeDiscord) StateRole(guildID, roleID string) (*discordgo.Role, error) {
+	for _, role := range f.guild.Roles {
+		if role.ID == roleID {
+			return role, nil
+		}
+	}
+	return nil, discordgo.ErrStateNotFound
+}
+
+func (f *fakeDiscord) StateVoiceState(guildID, userID string) (*discordgo.VoiceState, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if vs := f.voiceState(userID); vs != nil {
+		copied := *vs
+		return &copied, nil
+	}
+	return nil, discordgo.ErrStateNotFound
+}
+
+// restError returns an error like the ones of the Discord API
+func restError(status, code int) error {
+	return &discordgo.RESTError{
+		Response: &http.Response{StatusCode: status, Header: http.Header{}},
+		Message:  &discordgo.APIErrorMessage{Code: code},
+	}
+}
+
+// The tests write the stored data in a temporary directory
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "discmutebot-test")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := os.Chdir(dir); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// setup clears the state of the bot and returns a fake Discord for the test
+func setup(t *testing.T) *fakeDiscord {
+	t.Helper()
+	muteData = MuteData{
+		MutedUsers: make(map[string]MuteInfo),
+		Appeals:    make(map[string]Appeal),
+		Juries:     make(map[string]Jury),
+		VoteBans:   make(map[string]map[string]VoteBan),
+		Actions:    make(map[string]PendingAction),
+	}
+	history = nil
+	historyPrunedAt = time.Time{}
+	historyGuildEvents = make(map[string]int)
+	guildConfigs = make(map[string]GuildSettings)
+	botMuteChanges.changes = make(map[string]botMuteChange)
+	resumeOnce = sync.Once{}
+	testClock = newFakeClock()
+	clock = testClock
+	for _, file := range []string{muteFile, historyFile, guildConfigFile} {
+		os.Remove(file)
+	}
+	return newFakeDiscord()
+}
+
+// setVotesNeeded changes the threshold of the test guild
+func setVotesNeeded(votes int) {
+	settings := guildSettings(testGuild)
+	settings.VotesNeeded = votes
+	guildConfigs[testGuild] = settings
+}
+
+// Messages created by message, for their IDs
+var messageCount int
+
+// message returns a command sent by the user in the text channel, mentioning the users
+func message(author *discordgo.User, content string, mentions ...*discordgo.User) *discordgo.MessageCreate {
+	messageCount++
+	for _, mention := range mentions {
+		content = strings.Replace(content, "@"+mention.ID, "<@"+mention.ID+">", 1)
+	}
+	return &discordgo.MessageCreate{Message: &discordgo.Message{
+		ID:        fmt.Sprintf("msg-%d", messageCount),
+		GuildID:   testGuild,
+		ChannelID: testChannel,
+		Author:    author,
+		Content:   content,
+		Mentions:  mentions,
+	}}
+}
+
+// eventTypes returns the types of the events recorded in the history
+func eventTypes() []string {
+	var types []string
+	for _, event := range history {
+		types = append(types, event.Type)
+	}
+	return types
+}
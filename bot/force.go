@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/tu-usuario/DiscMuteBot/audit"
+)
+
+// Maximum duration of a mute applied by a moderator
+const FORCE_MUTE_MAX_MINUTES = 7 * 24 * 60
+
+var userMention = regexp.MustCompile(`^<@!?(\d+)>$`)
+
+// handleForceMute processes !forcemute @user [duration] [reason]
+func handleForceMute(s Discord, m *discordgo.MessageCreate, target *discordgo.User) {
+	if target.Bot {
+		s.ChannelMessageSend(m.ChannelID, "⚠️ You can't mute a bot.")
+		return
+	}
+
+	settings := guildSettings(m.GuildID)
+	duration := settings.MuteDuration()
+
+	// The first argument after the mention can be the duration, the rest is the reason
+	args := commandArgs(m.Content)
+	if len(args) > 0 {
+		if minutes, err := parseMinutes(args[0], 1, FORCE_MUTE_MAX_MINUTES); err == nil {
+			duration = time.Duration(minutes) * time.Minute
+			args = args[1:]
+		}
+	}
+	reason := strings.Join(args, " ")
+
+	muteInfo, exists := muteData.MutedUsers[target.ID]
+	if !exists {
+		muteInfo = MuteInfo{
+			MutedBy: make(map[string]time.Time),
+		}
+	}
+
+	request := muteRequest{
+		GuildID:     m.GuildID,
+		ChannelID:   m.ChannelID,
+		Target:      target,
+		Action:      "FORCE_MUTE",
+		InitiatorID: m.Author.ID,
+		Initiator:   m.Author.Username,
+		MessageID:   m.ID,
+		Duration:    duration,
+		Reason:      reason,
+	}
+	if !applyMute(s, request, &muteInfo) {
+		return
+	}
+
+	muteData.MutedUsers[target.ID] = muteInfo
+	saveMuteData()
+}
+
+// handleForceUnmute processes !forceunmute @user
+func handleForceUnmute(s Discord, m *discordgo.MessageCreate, target *discordgo.User) {
+	muteInfo, exists := muteData.MutedUsers[target.ID]
+	if !exists || !muteInfo.IsGloballyMuted {
+		s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("⚠️ %s is not muted.", target.Username))
+		return
+	}
+
+	manualMute := muteInfo.ManualMute
+	err := liftMute(s, m.GuildID, target.ID)
+	if err != nil && !muteChangeDeferred(err) {
+		s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("⚠️ Error unmuting %s", target.Username))
+		return
+	}
+
+	// The votes are discarded so the next vote doesn't mute again
+	muteInfo = muteData.MutedUsers[target.ID]
+	muteInfo.MutedBy = make(map[string]time.Time)
+	muteData.MutedUsers[target.ID] = muteInfo
+	saveMuteData()
+
+	recordEvent(audit.Event{
+		Type:        "FORCE_UNMUTE",
+		GuildID:     m.GuildID,
+		ChannelID:   m.ChannelID,
+		MessageID:   m.ID,
+		InitiatorID: m.Author.ID,
+		Initiator:   m.Author.Username,
+		TargetID:    target.ID,
+		Target:      target.Username,
+	})
+
+	msg := fmt.Sprintf("🔊 %s has been unmuted by %s", target.Username, m.Author.Username)
+	if manualMute {
+		msg += "\nThe server mute applied by hand before the bot mute is kept."
+	}
+	msg += pendingNote(err)
+	s.ChannelMessageSend(m.ChannelID, msg)
+}
+
+// commandArgs returns the arguments of a command without the command itself and the user mentions
+func commandArgs(content string) []string {
+	fields := strings.Fields(content)
+	if len(fields) == 0 {
+		return nil
+	}
+
+	var args []string
+	for _, field := range fields[1:] {
+		if !userMention.MatchString(field) {
+			args = append(args, field)
+		}
+	}
+	return args
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Time the gateway can be disconnected before /healthz fails, unless config.json sets another one
+const HEALTH_MAX_DISCONNECT = 5 * time.Minute
+
+// health tracks the state reported by /healthz and /readyz
+var health = struct {
+	sync.Mutex
+	ready          bool
+	connected      bool
+	disconnectedAt time.Time
+	// Last error of each store whose last write failed
+	persistenceErrors map[string]error
+}{
+	disconnectedAt:    time.Now(),
+	persistenceErrors: make(map[string]error),
+}
+
+// markReady is called when the Ready event fires, after the stored data was loaded
+func markReady() {
+	health.Lock()
+	defer health.Unlock()
+	health.ready = true
+}
+
+func markConnected(connected bool) {
+	health.Lock()
+	defer health.Unlock()
+	if health.connected && !connected {
+		health.disconnectedAt = time.Now()
+	}
+	health.connected = connected
+}
+
+// recordPersistence keeps the result of the last write of a store, like mute_data.json
+func recordPersistence(store string, err error) {
+	health.Lock()
+	defer health.Unlock()
+	if err != nil {
+		health.persistenceErrors[store] = err
+	} else {
+		delete(health.persistenceErrors, store)
+	}
+}
+
+// healthProblems returns why the bot isn't healthy, empty if it is
+func healthProblems(now time.Time) []string {
+	health.Lock()
+	defer health.Unlock()
+
+	var problems []string
+	maxDisconnect := HEALTH_MAX_DISCONNECT
+	if config.HealthMaxDisconnectSeconds > 0 {
+		maxDisconnect = time.Duration(config.HealthMaxDisconnectSeconds) * time.Second
+	}
+	if !health.connected && now.Sub(health.disconnectedAt) > maxDisconnect {
+		problems = append(problems, fmt.Sprintf("gateway disconnected for %s", now.Sub(health.disconnectedAt).Round(time.Second)))
+	}
+
+	var stores []string
+	for store := range health.persistenceErrors {
+		stores = append(stores, store)
+	}
+	sort.Strings(stores)
+	for _, store := range stores {
+		problems = append(problems, fmt.Sprintf("writes to %s are failing: %v", store, health.persistenceErrors[store]))
+	}
+	return problems
+}
+
+func isReady() bool {
+	health.Lock()
+	defer health.Unlock()
+	return health.ready
+}
+
+// handleHealthz fails when the gateway is disconnected for too long or persistence is failing,
+// so the orchestrator restarts the container
+func handleHealthz(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if problems := healthProblems(time.Now()); len(problems) > 0 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintln(w, strings.Join(problems, "\n"))
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+// handleReadyz passes once the bot loaded its data and Discord sent the Ready event
+func handleReadyz(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if !isReady() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintln(w, "not ready")
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tu-usuario/DiscMuteBot/audit"
+)
+
+func TestWebhookErrorsKeepHealthy(t *testing.T) {
+	setup(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	config.AuditSinks = []string{"webhook"}
+	config.AuditWebhookURL = server.URL
+	previous := auditLog
+	t.Cleanup(func() {
+		config.AuditSinks, config.AuditWebhookURL = nil, ""
+		auditLog = previous
+	})
+
+	var err error
+	auditLog, err = openAuditLog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The closed webhook drops the event
+	auditLog.Close()
+	recordEvent(audit.Event{Type: "VOTE", GuildID: testGuild})
+
+	for _, problem := range healthProblems(time.Now()) {
+		if strings.Contains(problem, "audit log") {
+			t.Errorf("unhealthy: %s", problem)
+		}
+	}
+	recorder := httptest.NewRecorder()
+	metricsRegistry.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.Contains(recorder.Body.String(), "discmutebot_audit_webhook_errors_total 1") {
+		t.Errorf("webhook error not counted:\n%s", recorder.Body.String())
+	}
+}
+
+// metricValue reads a series from the metrics endpoint, 0 when it's missing
+func metricValue(t *testing.T, series string) float64 {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	metricsRegistry.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	for _, line := range strings.Split(recorder.Body.String(), "\n") {
+		if value, found := strings.CutPrefix(line, series+" "); found {
+			number, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return number
+		}
+	}
+	return 0
+}
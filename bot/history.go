@@ -0,0 +1,386 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/tu-usuario/DiscMuteBot/audit"
+)
+
+const (
+	// Events shown per page of !history
+	HISTORY_PAGE_SIZE = 10
+	// Events of each guild kept in memory and in the history file, the oldest are dropped first
+	HISTORY_MAX_EVENTS = 10000
+	// Time between the checks of log_retention_days on the history
+	HISTORY_PRUNE_INTERVAL = time.Hour
+	// Maximum length of the description of an embed in Discord
+	EMBED_DESCRIPTION_LIMIT = 4096
+)
+
+var (
+	history     []audit.Event
+	historyFile = "history.jsonl"
+	// Last time the old events were dropped from the history
+	historyPrunedAt time.Time
+	// Events of each guild in the history
+	historyGuildEvents = make(map[string]int)
+)
+
+// recordEvent registers the event in the log files, the history and the mod-log channel
+func recordEvent(event audit.Event) {
+	if event.Time.IsZero() {
+		event.Time = clock.Now()
+	}
+	if event.Initiator == "" {
+		event.Initiator = "System"
+	}
+	if event.GuildID != "" && event.Threshold == 0 {
+		event.Threshold = guildSettings(event.GuildID).VotesNeeded
+	}
+
+	// Voice channel where the target is
+	if session != nil && event.TargetID != "" && event.VoiceChannelID == "" {
+		if vs, err := session.StateVoiceState(event.GuildID, event.TargetID); err == nil {
+			event.VoiceChannelID = vs.ChannelID
+		}
+	}
+
+	recordPersistence("audit log", auditLog.Log(event))
+	appendHistory(event)
+	countEvent(event)
+
+	if session != nil {
+		postModLog(session, event)
+	}
+}
+
+// voterIDs returns the users with active votes in the mute info
+func voterIDs(muteInfo MuteInfo) []string {
+	var voters []string
+	for voterID := range muteInfo.MutedBy {
+		voters = append(voters, voterID)
+	}
+	return voters
+}
+
+func loadHistory() {
+	file, err := os.Open(historyFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			slog.Error("Error reading history file", "file", historyFile, "error", err)
+		}
+		return
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var event audit.Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			slog.Warn("Error deserializing history event", "file", historyFile, "error", err)
+			continue
+		}
+		history = append(history, event)
+	}
+	if err := scanner.Err(); err != nil {
+		slog.Error("Error reading history file", "file", historyFile, "error", err)
+		return
+	}
+	pruneHistory(clock.Now())
+}
+
+func appendHistory(event audit.Event) {
+	history = append(history, event)
+	historyGuildEvents[event.GuildID]++
+
+	// The extra events over the maximum avoid rewriting the file on every event
+	now := clock.Now()
+	if historyGuildEvents[event.GuildID] > HISTORY_MAX_EVENTS+HISTORY_MAX_EVENTS/10 || now.Sub(historyPrunedAt) >= HISTORY_PRUNE_INTERVAL {
+		if pruneHistory(now) {
+			// The file was rewritten with the new event
+			return
+		}
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		slog.Error("Error serializing history event", "guild_id", event.GuildID, "type", event.Type, "error", err)
+		return
+	}
+
+	file, err := os.OpenFile(historyFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		slog.Error("Error opening history file", "file", historyFile, "error", err)
+		recordPersistence(historyFile, err)
+		return
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(data, '\n'))
+	if err != nil {
+		slog.Error("Error writing history event", "file", historyFile, "error", err)
+	}
+	recordPersistence(historyFile, err)
+}
+
+// pruneHistory drops the events older than log_retention_days and the oldest events of each guild
+// over HISTORY_MAX_EVENTS, and rewrites the history file if it dropped any. Returns whether it did.
+// A busy guild doesn't drop the events of the others.
+func pruneHistory(now time.Time) bool {
+	historyPrunedAt = now
+
+	var limit time.Time
+	if config.LogRetentionDays > 0 {
+		limit = now.AddDate(0, 0, -config.LogRetentionDays)
+	}
+
+	// From the newest, the events of each guild are kept until it has the maximum
+	kept := make(map[string]int)
+	keep := make([]bool, len(history))
+	dropped := 0
+	for i := len(history) - 1; i >= 0; i-- {
+		event := history[i]
+		if kept[event.GuildID] >= HISTORY_MAX_EVENTS || event.Time.Before(limit) {
+			dropped++
+			continue
+		}
+		kept[event.GuildID]++
+		keep[i] = true
+	}
+	historyGuildEvents = kept
+	if dropped == 0 {
+		return false
+	}
+
+	slog.Info("Dropping old events from the history", "file", historyFile, "events", dropped,
+		"retention_days", config.LogRetentionDays, "max_events", HISTORY_MAX_EVENTS)
+	events := make([]audit.Event, 0, len(history)-dropped)
+	for i, event := range history {
+		if keep[i] {
+			events = append(events, event)
+		}
+	}
+	history = events
+	saveHistory()
+	return true
+}
+
+// saveHistory replaces the history file with the events in memory
+func saveHistory() {
+	var data []byte
+	for _, event := range history {
+		line, err := json.Marshal(event)
+		if err != nil {
+			slog.Error("Error serializing history event", "guild_id", event.GuildID, "type", event.Type, "error", err)
+			continue
+		}
+		data = append(append(data, line...), '\n')
+	}
+
+	// Written aside and renamed, so a failed write doesn't lose the whole history
+	temporary := historyFile + ".tmp"
+	err := os.WriteFile(temporary, data, 0644)
+	if err == nil {
+		err = os.Rename(temporary, historyFile)
+	}
+	if err != nil {
+		os.Remove(temporary)
+		slog.Error("Error saving history file", "file", historyFile, "error", err)
+	}
+	recordPersistence(historyFile, err)
+}
+
+// userHistory returns the events of the user in the guild that are shown in !history, newest first
+func userHistory(guildID, userID string) []audit.Event {
+	var events []audit.Event
+	for i := len(history) - 1; i >= 0; i-- {
+		event := history[i]
+		if event.GuildID != guildID || event.TargetID != userID {
+			continue
+		}
+		// Single votes are summarized in the mutes
+		if event.Type == "VOTE" || event.Type == "JURY_VOTE" {
+			continue
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+// handleHistory processes !history @user
+func handleHistory(s Discord, m *discordgo.MessageCreate, target *discordgo.User) {
+	embed, components := historyPage(m.GuildID, target.ID, target.Username, 0)
+	_, err := s.ChannelMessageSendComplex(m.ChannelID, &discordgo.MessageSend{
+		Embeds:     []*discordgo.MessageEmbed{embed},
+		Components: components,
+	})
+	if err != nil {
+		slog.Error("Error sending history", "guild_id", m.GuildID, "user_id", target.ID, "error", err)
+	}
+}
+
+// handleHistoryPage changes the page of a history message from its buttons
+func handleHistoryPage(s Discord, i *discordgo.InteractionCreate, args string) {
+	if !requireInteractionPermission(s, i, "history") {
+		return
+	}
+
+	// history:<user ID>:<page>
+	parts := strings.Split(args, ":")
+	if len(parts) != 2 {
+		return
+	}
+	page, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return
+	}
+
+	username := "User " + parts[0]
+	if user, err := s.User(parts[0]); err == nil {
+		username = user.Username
+	}
+
+	embed, components := historyPage(i.GuildID, parts[0], username, page)
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Embeds:     []*discordgo.MessageEmbed{embed},
+			Components: components,
+		},
+	})
+	if err != nil {
+		slog.Error("Error updating history message", "guild_id", i.GuildID, "user_id", parts[0], "error", err)
+	}
+}
+
+func historyPage(guildID, userID, username string, page int) (*discordgo.MessageEmbed, []discordgo.MessageComponent) {
+	events := userHistory(guildID, userID)
+
+	pages := (len(events) + HISTORY_PAGE_SIZE - 1) / HISTORY_PAGE_SIZE
+	if pages == 0 {
+		pages = 1
+	}
+	if page < 0 {
+		page = 0
+	}
+	if page >= pages {
+		page = pages - 1
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:  fmt.Sprintf("📜 Moderation history of %s", username),
+		Color:  0x3498db,
+		Footer: &discordgo.MessageEmbedFooter{Text: fmt.Sprintf("Page %d/%d - %d events", page+1, pages, len(events))},
+	}
+
+	if len(events) == 0 {
+		embed.Description = "No moderation events for this user."
+		return embed, []discordgo.MessageComponent{}
+	}
+
+	var description strings.Builder
+	end := (page + 1) * HISTORY_PAGE_SIZE
+	if end > len(events) {
+		end = len(events)
+	}
+	for _, event := range events[page*HISTORY_PAGE_SIZE : end] {
+		description.WriteString(fmt.Sprintf("`%s` %s\n", event.Time.Format("2006-01-02 15:04"), describeEvent(event)))
+	}
+	embed.Description = truncate(description.String(), EMBED_DESCRIPTION_LIMIT)
+
+	if pages == 1 {
+		return embed, []discordgo.MessageComponent{}
+	}
+
+	return embed, []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{
+					Label:    "Previous",
+					Style:    discordgo.SecondaryButton,
+					CustomID: fmt.Sprintf("history:%s:%d", userID, page-1),
+					Disabled: page == 0,
+				},
+				discordgo.Button{
+					Label:    "Next",
+					Style:    discordgo.SecondaryButton,
+					CustomID: fmt.Sprintf("history:%s:%d", userID, page+1),
+					Disabled: page >= pages-1,
+				},
+			},
+		},
+	}
+}
+
+// describeEvent returns a line of the history for the event
+func describeEvent(event audit.Event) string {
+	duration := ""
+	if !event.ExpiresAt.IsZero() {
+		duration = event.ExpiresAt.Sub(event.Time).Round(time.Minute).String()
+	}
+
+	var line string
+	switch event.Type {
+	case "MUTE":
+		line = fmt.Sprintf("🔇 Muted for %s by %s", duration, event.Initiator)
+	case "FORCE_MUTE":
+		line = fmt.Sprintf("🔨 Force muted for %s by %s", duration, event.Initiator)
+	case "UNMUTE":
+		line = "🔊 Mute expired"
+	case "FORCE_UNMUTE":
+		line = fmt.Sprintf("🕊️ Pardoned by %s", event.Initiator)
+	case "CLEAN":
+		line = fmt.Sprintf("🧹 Votes cleaned by %s", event.Initiator)
+	case "APPEAL":
+		line = "📨 Appealed"
+	case "APPEAL_ACCEPTED":
+		line = fmt.Sprintf("✅ Appeal accepted by %s", event.Initiator)
+	case "APPEAL_REJECTED":
+		line = fmt.Sprintf("❌ Appeal rejected by %s", event.Initiator)
+	case "JURY_SELECTED":
+		line = fmt.Sprintf("⚖️ Jury of %d selected", event.Votes)
+	case "JURY_CONFIRMED":
+		line = fmt.Sprintf("⚖️ Jury confirmed the mute (%d confirmations)", event.Votes)
+	case "JURY_REJECTED":
+		line = fmt.Sprintf("⚖️ Jury rejected the mute (%d confirmations)", event.Votes)
+	case "VOTEBAN":
+		line = fmt.Sprintf("🚫 Banned from voting by %s", event.Initiator)
+		if duration != "" {
+			line += " for " + duration
+		}
+	case "VOTEUNBAN":
+		line = fmt.Sprintf("✅ Allowed to vote again by %s", event.Initiator)
+	case "MANUAL_OVERRIDE":
+		line = fmt.Sprintf("🛠️ Unmuted by hand by %s", event.Initiator)
+	case "DEAD_LETTER":
+		line = fmt.Sprintf("⚠️ Discord failed to apply a mute change after %d attempts", event.Votes)
+	default:
+		line = fmt.Sprintf("%s by %s", event.Type, event.Initiator)
+	}
+
+	if len(event.Voters) > 0 {
+		var mentions []string
+		for _, voterID := range event.Voters {
+			mentions = append(mentions, "<@"+voterID+">")
+		}
+		line += " - voters: " + strings.Join(mentions, ", ")
+	}
+	if event.Reason != "" {
+		reason := event.Reason
+		if cut := cutRunes(reason, 100); cut != reason {
+			reason = cut + "..."
+		}
+		line += "\n> " + reason
+	}
+	return line
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tu-usuario/DiscMuteBot/audit"
+)
+
+// historyFileEvents returns the number of events in the history file
+func historyFileEvents(t *testing.T) int {
+	t.Helper()
+	file, err := os.Open(historyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	lines := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines++
+	}
+	return lines
+}
+
+func TestHistoryRetention(t *testing.T) {
+	setup(t)
+	config.LogRetentionDays = 30
+	t.Cleanup(func() { config.LogRetentionDays = 0 })
+	historyPrunedAt = clock.Now()
+
+	appendHistory(audit.Event{Type: "MUTE", GuildID: testGuild, Time: clock.Now().AddDate(0, 0, -31)})
+	appendHistory(audit.Event{Type: "MUTE", GuildID: testGuild, Time: clock.Now().AddDate(0, 0, -29)})
+	if len(history) != 2 {
+		t.Fatalf("history has %d events before the check, want 2", len(history))
+	}
+
+	testClock.Advance(HISTORY_PRUNE_INTERVAL)
+	appendHistory(audit.Event{Type: "MUTE", GuildID: testGuild, Time: clock.Now()})
+
+	if len(history) != 2 || history[0].Time.Before(clock.Now().AddDate(0, 0, -30)) {
+		t.Errorf("history = %+v, want the events of the last 30 days", history)
+	}
+	if events := historyFileEvents(t); events != 2 {
+		t.Errorf("history file has %d events, want 2", events)
+	}
+}
+
+func TestHistoryMaxEventsByGuild(t *testing.T) {
+	setup(t)
+	// The quiet guild has the oldest event
+	appendHistory(audit.Event{Type: "MUTE", GuildID: "quiet", TargetID: "bob", Target: "bob", Time: clock.Now()})
+	for i := 0; i <= HISTORY_MAX_EVENTS+HISTORY_MAX_EVENTS/10; i++ {
+		appendHistory(audit.Event{Type: "VOTE", GuildID: testGuild, Time: clock.Now()})
+	}
+
+	if len(history) != HISTORY_MAX_EVENTS+1 {
+		t.Errorf("history has %d events, want %d of the busy guild and the quiet one", len(history), HISTORY_MAX_EVENTS+1)
+	}
+	if events := historyFileEvents(t); events != HISTORY_MAX_EVENTS+1 {
+		t.Errorf("history file has %d events, want %d", events, HISTORY_MAX_EVENTS+1)
+	}
+	if events := userHistory("quiet", "bob"); len(events) != 1 {
+		t.Errorf("history of the quiet guild = %+v", events)
+	}
+	if stats := computeStats(history, "quiet", time.Time{}, clock.Now()); stats.Mutes != 1 {
+		t.Errorf("mutes of the quiet guild = %d, want 1", stats.Mutes)
+	}
+
+	history = nil
+	loadHistory()
+	if len(history) != HISTORY_MAX_EVENTS+1 {
+		t.Errorf("loaded %d events, want %d", len(history), HISTORY_MAX_EVENTS+1)
+	}
+}
+
+func TestHistoryPageFitsInEmbed(t *testing.T) {
+	setup(t)
+	target := "bob"
+	var voters []string
+	for i := 0; i < 300; i++ {
+		voters = append(voters, strings.Repeat("1", 18))
+	}
+	for i := 0; i < HISTORY_PAGE_SIZE; i++ {
+		appendHistory(audit.Event{Type: "MUTE", GuildID: testGuild, TargetID: target, Voters: voters,
+			Reason: strings.Repeat("ñ", 200), Time: clock.Now(), ExpiresAt: clock.Now().Add(time.Hour)})
+	}
+
+	embed, _ := historyPage(testGuild, target, target, 0)
+
+	if length := len([]rune(embed.Description)); length > EMBED_DESCRIPTION_LIMIT {
+		t.Errorf("description has %d characters, the limit is %d", length, EMBED_DESCRIPTION_LIMIT)
+	}
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/tu-usuario/DiscMuteBot/audit"
+)
+
+const (
+	JURY_SIZE     = 3
+	JURY_DURATION = 2 * time.Minute
+
+	JURY_PENDING   = "pending"
+	JURY_CONFIRMED = "confirmed"
+	JURY_REJECTED  = "rejected"
+)
+
+type Jury struct {
+	ID         string          `json:"id"`
+	GuildID    string          `json:"guild_id"`
+	ChannelID  string          `json:"channel_id"`
+	MessageID  string          `json:"message_id"`
+	TargetID   string          `json:"target_id"`
+	TargetName string          `json:"target_name"`
+	Voters     []string        `json:"voters"`
+	Jurors     []string        `json:"jurors"`
+	Verdicts   map[string]bool `json:"verdicts"`
+	CreatedAt  time.Time       `json:"created_at"`
+	Deadline   time.Time       `json:"deadline"`
+	Result     string          `json:"result"`
+}
+
+// pendingJury returns the jury deciding on the target, if any
+func pendingJury(guildID, targetID string) (Jury, bool) {
+	for _, jury := range muteData.Juries {
+		if jury.GuildID == guildID && jury.TargetID == targetID && jury.Result == JURY_PENDING {
+			return jury, true
+		}
+	}
+	return Jury{}, false
+}
+
+// startJury picks the jurors and asks them to confirm the mute.
+// Returns false if there are no eligible jurors, so the caller can mute directly.
+func startJury(s Discord, guildID, channelID string, target *discordgo.User, muteInfo MuteInfo) bool {
+	guild, err := s.StateGuild(guildID)
+	if err != nil {
+		slog.Error("Error getting server information", "guild_id", guildID, "error", err)
+		return false
+	}
+
+	settings := guildSettings(guildID)
+	jurors := selectJurors(s, guild, target.ID, muteInfo.MutedBy, settings.JurySize)
+	if len(jurors) == 0 {
+		slog.Info("No eligible jurors, muting directly", "guild_id", guildID, "user_id", target.ID)
+		return false
+	}
+
+	jury := Jury{
+		ID:         nextID(&muteData.LastJuryID, muteData.Juries),
+		GuildID:    guildID,
+		ChannelID:  channelID,
+		TargetID:   target.ID,
+		TargetName: target.Username,
+		Jurors:     jurors,
+		Verdicts:   make(map[string]bool),
+		CreatedAt:  clock.Now(),
+		Deadline:   clock.Now().Add(settings.JuryDuration()),
+		Result:     JURY_PENDING,
+	}
+	for voterID := range muteInfo.MutedBy {
+		jury.Voters = append(jury.Voters, voterID)
+	}
+
+	msg, err := s.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+		Content:    juryMessage(jury),
+		Components: juryButtons(jury.ID),
+	})
+	if err != nil {
+		slog.Error("Error posting jury message", "guild_id", guildID, "channel_id", channelID, "error", err)
+		return false
+	}
+	jury.MessageID = msg.ID
+
+	muteData.Juries[jury.ID] = jury
+	saveMuteData()
+
+	// Register jury in log
+	recordEvent(audit.Event{
+		Type:      "JURY_SELECTED",
+		GuildID:   guildID,
+		ChannelID: channelID,
+		MessageID: jury.MessageID,
+		TargetID:  target.ID,
+		Target:    target.Username,
+		Votes:     len(jurors),
+		Voters:    jury.Voters,
+		ExpiresAt: jury.Deadline,
+		Reason:    "Jurors: <@" + strings.Join(jurors, ">, <@") + ">",
+	})
+	slog.Info("Jury selected", "guild_id", guildID, "user_id", target.ID, "jury_id", jury.ID, "jurors", jurors)
+
+	// Decide with the votes received when the time is up
+	scheduleJuryExpiry(s, jury)
+	return true
+}
+
+// scheduleJuryExpiry decides the jury at its deadline
+func scheduleJuryExpiry(s Discord, jury Jury) {
+	clock.AfterFunc(max(timeUntil(jury.Deadline), 0), func() {
+		// During a shutdown the next process decides the jury
+		if !beginWork() {
+			return
+		}
+		defer endWork()
+
+		expireJury(s, jury.ID)
+	})
+}
+
+// selectJurors picks random members in voice channels who didn't vote, preferring the target's channel
+func selectJurors(s Discord, guild *discordgo.Guild, targetID string, voters map[string]time.Time, size int) []string {
+	targetChannel := ""
+	for _, vs := range guild.VoiceStates {
+		if vs.UserID == targetID {
+			targetChannel = vs.ChannelID
+			break
+		}
+	}
+
+	var sameChannel, otherChannels []string
+	for _, vs := range guild.VoiceStates {
+		if vs.UserID == targetID || vs.UserID == s.BotUser().ID {
+			continue
+		}
+		if _, voted := voters[vs.UserID]; voted {
+			continue
+		}
+
+		// Bots can't be jurors
+		member := vs.Member
+		if member == nil {
+			member, _ = s.StateMember(guild.ID, vs.UserID)
+		}
+		if member != nil && member.User != nil && member.User.Bot {
+			continue
+		}
+
+		if targetChannel != "" && vs.ChannelID == targetChannel {
+			sameChannel = append(sameChannel, vs.UserID)
+		} else {
+			otherChannels = append(otherChannels, vs.UserID)
+		}
+	}
+
+	rand.Shuffle(len(sameChannel), func(i, j int) { sameChannel[i], sameChannel[j] = sameChannel[j], sameChannel[i] })
+	rand.Shuffle(len(otherChannels), func(i, j int) { otherChannels[i], otherChannels[j] = otherChannels[j], otherChannels[i] })
+
+	candidates := append(sameChannel, otherChannels...)
+	if len(candidates) > size {
+		candidates = candidates[:size]
+	}
+	return candidates
+}
+
+// handleJuryVote registers the verdict of a juror from the buttons of the jury message
+func handleJuryVote(s Discord, i *discordgo.InteractionCreate, juryID string, confirm bool) {
+	juror := interactionUser(i)
+
+	jury, exists := muteData.Juries[juryID]
+	if !exists {
+		respondEphemeral(s, i, "❌ Jury not found.")
+		return
+	}
+	if jury.Result != JURY_PENDING {
+		respondEphemeral(s, i, "⚠️ This jury has already reached a verdict.")
+		return
+	}
+
+	isJuror := false
+	for _, jurorID := range jury.Jurors {
+		if jurorID == juror.ID {
+			isJuror = true
+			break
+		}
+	}
+	if !isJuror {
+		respondEphemeral(s, i, "⚠️ You are not part of this jury.")
+		return
+	}
+	if _, voted := jury.Verdicts[juror.ID]; voted {
+		respondEphemeral(s, i, "⚠️ You have already given your verdict.")
+		return
+	}
+
+	jury.Verdicts[juror.ID] = confirm
+	muteData.Juries[jury.ID] = jury
+	saveMuteData()
+
+	confirms, rejects := countVerdicts(jury)
+	recordEvent(audit.Event{
+		Type:        "JURY_VOTE",
+		GuildID:     jury.GuildID,
+		ChannelID:   jury.ChannelID,
+		MessageID:   jury.MessageID,
+		InitiatorID: juror.ID,
+		Initiator:   juror.Username,
+		TargetID:    jury.TargetID,
+		Target:      jury.TargetName,
+		Votes:       confirms,
+	})
+
+	respondEphemeral(s, i, "✅ Your verdict has been registered.")
+
+	// Decide as soon as the majority is known
+	majority := len(jury.Jurors)/2 + 1
+	if confirms >= majority {
+		resolveJury(s, jury, true)
+	} else if rejects > len(jury.Jurors)-majority {
+		resolveJury(s, jury, false)
+	}
+}
+
+// expireJury decides a pending jury when the time limit is reached
+func expireJury(s Discord, juryID string) {
+	jury, exists := muteData.Juries[juryID]
+	if !exists || jury.Result != JURY_PENDING {
+		return
+	}
+
+	// Jurors who didn't answer don't confirm the mute
+	confirms, _ := countVerdicts(jury)
+	resolveJury(s, jury, confirms >= len(jury.Jurors)/2+1)
+}
+
+// resolveJury stores the verdict and mutes the target if the jury confirmed it
+func resolveJury(s Discord, jury Jury, confirmed bool) {
+	confirms, _ := countVerdicts(jury)
+
+	muteInfo, exists := muteData.MutedUsers[jury.TargetID]
+	if !exists {
+		muteInfo = MuteInfo{
+			GuildID: jury.GuildID,
+			MutedBy: make(map[string]time.Time),
+		}
+	}
+
+	verdict := audit.Event{
+		GuildID:   jury.GuildID,
+		ChannelID: jury.ChannelID,
+		MessageID: jury.MessageID,
+		TargetID:  jury.TargetID,
+		Target:    jury.TargetName,
+		Votes:     confirms,
+		Voters:    jury.Voters,
+	}
+
+	if confirmed {
+		jury.Result = JURY_CONFIRMED
+		verdict.Type = "JURY_CONFIRMED"
+		recordEvent(verdict)
+
+		target, err := s.User(jury.TargetID)
+		if err != nil {
+			slog.Error("Error getting user info", "guild_id", jury.GuildID, "user_id", jury.TargetID, "error", err)
+			target = &discordgo.User{ID: jury.TargetID, Username: jury.TargetName}
+		}
+		applyMute(s, muteRequest{
+			GuildID:   jury.GuildID,
+			ChannelID: jury.ChannelID,
+			Target:    target,
+			Action:    "MUTE",
+			Initiator: "Jury",
+			Duration:  guildSettings(jury.GuildID).MuteDuration(),
+		}, &muteInfo)
+	} else {
+		jury.Result = JURY_REJECTED
+		verdict.Type = "JURY_REJECTED"
+		recordEvent(verdict)
+
+		// The votes are discarded, a new campaign has to start from scratch
+		muteInfo.MutedBy = make(map[string]time.Time)
+		s.ChannelMessageSend(jury.ChannelID, fmt.Sprintf("⚖️ The jury didn't confirm the mute of %s. The votes have been discarded.",
+			jury.TargetName))
+	}
+
+	muteData.Juries[jury.ID] = jury
+	muteData.MutedUsers[jury.TargetID] = muteInfo
+	saveMuteData()
+
+	// Replace the buttons with the verdict
+	content := juryMessage(jury)
+	_, err := s.ChannelMessageEditComplex(&discordgo.MessageEdit{
+		ID:         jury.MessageID,
+		Channel:    jury.ChannelID,
+		Content:    &content,
+		Components: []discordgo.MessageComponent{},
+	})
+	if err != nil {
+		slog.Error("Error updating jury message", "guild_id", jury.GuildID, "jury_id", jury.ID, "error", err)
+	}
+}
+
+func countVerdicts(jury Jury) (confirms, rejects int) {
+	for _, confirm := range jury.Verdicts {
+		if confirm {
+			confirms++
+		} else {
+			rejects++
+		}
+	}
+	return confirms, rejects
+}
+
+func juryMessage(jury Jury) string {
+	var mentions []string
+	for _, jurorID := range jury.Jurors {
+		mentions = append(mentions, "<@"+jurorID+">")
+	}
+
+	confirms, rejects := countVerdicts(jury)
+	switch jury.Result {
+	case JURY_CONFIRMED:
+		return fmt.Sprintf("⚖️ **Jury #%s**: the mute of **%s** has been confirmed (%d/%d).",
+			jury.ID, jury.TargetName, confirms, len(jury.Jurors))
+	case JURY_REJECTED:
+		return fmt.Sprintf("⚖️ **Jury #%s**: the mute of **%s** has been rejected (%d confirmed, %d rejected, %d didn't answer).",
+			jury.ID, jury.TargetName, confirms, rejects, len(jury.Jurors)-confirms-rejects)
+	}
+
+	return fmt.Sprintf("⚖️ **Jury #%s**: %s, you have been selected to decide whether **%s** should be muted.\n"+
+		"The votes reached the threshold. The mute needs the confirmation of %d of %d jurors within %d minutes.",
+		jury.ID, strings.Join(mentions, ", "), jury.TargetName, len(jury.Jurors)/2+1, len(jury.Jurors),
+		int(jury.Deadline.Sub(jury.CreatedAt).Minutes()))
+}
+
+func juryButtons(juryID string) []discordgo.MessageComponent {
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{
+					Label:    "Confirm mute",
+					Style:    discordgo.DangerButton,
+					CustomID: "jury_confirm:" + juryID,
+				},
+				discordgo.Button{
+					Label:    "Reject",
+					Style:    discordgo.SecondaryButton,
+					CustomID: "jury_reject:" + juryID,
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import "fmt"
+
+// Languages of the messages the members see when they vote and get muted
+const (
+	LANG_EN = "en"
+	LANG_ES = "es"
+)
+
+// Spanish messages, by their English format
+var spanish = map[string]string{
+	"⚠️ You can't vote to mute yourself.":                                                  "⚠️ No puedes votar para silenciarte a ti mismo.",
+	"⚠️ You can't vote to mute a bot.":                                                     "⚠️ No puedes votar para silenciar a un bot.",
+	"🚫 You have been banned from voting in this server.":                                   "🚫 Se te ha prohibido votar en este servidor.",
+	"🚫 You have been banned from voting in this server. The ban ends in: %s":               "🚫 Se te ha prohibido votar en este servidor. La prohibición termina en: %s",
+	"🔇 %s is already muted in voice channels. The mute will end in: %s":                    "🔇 %s ya está silenciado en los canales de voz. El silencio terminará en: %s",
+	"❌ Error getting member information.":                                                  "❌ Error al obtener la información del miembro.",
+	"🛡️ %s has an immune role and can't be voted.":                                         "🛡️ %s tiene un rol inmune y no se puede votar.",
+	"⚖️ A jury is already deciding whether to mute %s. The verdict will be known in: %s":   "⚖️ Un jurado ya está decidiendo si silenciar a %s. El veredicto se conocerá en: %s",
+	"⚠️ You've already voted to mute %s. Your vote expires in: %s":                         "⚠️ Ya has votado para silenciar a %s. Tu voto expira en: %s",
+	"✅ Vote registered against %s. Current votes: %d/%d\nYour vote expires in %d minutes.": "✅ Voto registrado contra %s. Votos actuales: %d/%d\nTu voto expira en %d minutos.",
+	"❌ Error getting server information.":                                                  "❌ Error al obtener la información del servidor.",
+	"❌ Error muting %s. It's possible they're not in a voice channel.":                     "❌ Error al silenciar a %s. Es posible que no esté en un canal de voz.",
+	"🔇 %s has been muted in voice channels for %d minutes.":                                "🔇 %s ha sido silenciado en los canales de voz durante %d minutos.",
+	"🔇 %s will be muted when they join a voice channel. The mute will last %d minutes.":    "🔇 %s será silenciado cuando entre en un canal de voz. El silencio durará %d minutos.",
+	"\nReason: %s": "\nMotivo: %s",
+}
+
+// translate formats the message in the language of the guild, English if it has no translation
+func translate(guildID, format string, args ...interface{}) string {
+	if guildSettings(guildID).Language == LANG_ES {
+		if translated, exists := spanish[format]; exists {
+			format = translated
+		}
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
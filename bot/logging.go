@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// setupLogging configures the operational logs from config.json: log_format is text or json,
+// log_level is debug, info, warn or error. Messages of the standard log package, like the ones
+// of discordgo, go through the same handler.
+func setupLogging() error {
+	var level slog.Level
+	switch strings.ToLower(config.LogLevel) {
+	case "", "info":
+		level = slog.LevelInfo
+	case "debug":
+		level = slog.LevelDebug
+	case "warn", "warning":
+		level = slog.LevelWarn
+	case "error":
+		level = slog.LevelError
+	default:
+		return fmt.Errorf("invalid log_level %q, use debug, info, warn or error", config.LogLevel)
+	}
+
+	options := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	switch strings.ToLower(config.LogFormat) {
+	case "", "text":
+		handler = slog.NewTextHandler(os.Stderr, options)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, options)
+	default:
+		return fmt.Errorf("invalid log_format %q, use text or json", config.LogFormat)
+	}
+
+	slog.SetDefault(slog.New(handler))
+	// The default handler already adds the time
+	log.SetFlags(0)
+	return nil
+}
+
+// fatal logs the error and stops the bot
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
@@ -1,18 +1,25 @@
 package main
 
 import (
-	"encoding/csv"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
+	// Time zones for log_timezone in images without tzdata
+	_ "time/tzdata"
 
 	"github.com/bwmarrin/discordgo"
+
+	"github.com/tu-usuario/DiscMuteBot/audit"
 )
 
+// Default rules, each guild can change them with !config
 const (
 	VOTE_DURATION = 10 * time.Minute
 	MUTE_DURATION = 5 * time.Minute
@@ -20,54 +27,156 @@ const (
 )
 
 type MuteInfo struct {
+	GuildID         string               `json:"guild_id"`
 	MutedBy         map[string]time.Time `json:"muted_by"`
 	MuteExpiry      time.Time            `json:"mute_expiry"`
 	IsGloballyMuted bool                 `json:"is_globally_muted"`
+	Reason          string               `json:"reason,omitempty"`
+	// The member already had a server mute from a moderator when the bot muted them,
+	// so it's left in place when the bot mute ends
+	ManualMute bool `json:"manual_mute,omitempty"`
+	// The member was also timed out, see sanction.go
+	TimedOut bool `json:"timed_out,omitempty"`
 }
 
 type MuteData struct {
 	MutedUsers map[string]MuteInfo `json:"muted_users"`
+	Appeals    map[string]Appeal   `json:"appeals"`
+	Juries     map[string]Jury     `json:"juries"`
+	// Vote bans by guild and user
+	VoteBans map[string]map[string]VoteBan `json:"vote_bans"`
+	// Mute changes waiting to be retried by guild and user, and the ones abandoned
+	Actions     map[string]PendingAction `json:"actions"`
+	DeadLetters []PendingAction          `json:"dead_letters"`
+	// Last ID given to a queued action
+	LastActionID int `json:"last_action_id"`
+	// Last IDs given to an appeal and a jury
+	LastAppealID int `json:"last_appeal_id"`
+	LastJuryID   int `json:"last_jury_id"`
+}
+
+// nextID increases the counter until it gives an ID not in use, data saved before the counter
+// existed only has the IDs
+func nextID[V any](last *int, used map[string]V) string {
+	for {
+		*last++
+		id := strconv.Itoa(*last)
+		if _, exists := used[id]; !exists {
+			return id
+		}
+	}
 }
 
 var (
 	muteData MuteData
 	muteFile = "mute_data.json"
-	config   struct {
-		Token string `json:"token"`
+	// Discord session used outside of the event handlers
+	session Discord
+	config  struct {
+		Token          string            `json:"token"`
+		AppealChannels map[string]string `json:"appeal_channels"`
+		JuryMode       bool              `json:"jury_mode"`
+		JurySize       int               `json:"jury_size"`
+		JuryMinutes    int               `json:"jury_minutes"`
+		// Version of the header of new log files, 1 for the original columns only
+		LogCSVVersion int `json:"log_csv_version"`
+		// Where the audit events are written: csv, jsonl, stdout and webhook
+		AuditSinks      []string `json:"audit_sinks"`
+		AuditWebhookURL string   `json:"audit_webhook_url"`
+		// Retention of the daily log files, 0 disables each rule
+		LogRetentionDays     int `json:"log_retention_days"`
+		LogCompressAfterDays int `json:"log_compress_after_days"`
+		LogMaxTotalMB        int `json:"log_max_total_mb"`
+		// Permissions of new log files in octal, like "0640"
+		LogFileMode string `json:"log_file_mode"`
+		// Time zone of the names and timestamps of the log files, like "Europe/Madrid"
+		LogTimezone string `json:"log_timezone"`
+		// Seconds to finish the running commands and save the data when the bot stops
+		ShutdownTimeoutSeconds int `json:"shutdown_timeout_seconds"`
+		// Address of the HTTP server with the metrics and health checks, like ":8080". Disabled if empty.
+		HTTPAddr string `json:"http_addr"`
+		// Seconds the gateway can be disconnected before /healthz fails
+		HealthMaxDisconnectSeconds int `json:"health_max_disconnect_seconds"`
+		// Operational logs: text or json, and the minimum level (debug, info, warn or error)
+		LogFormat string `json:"log_format"`
+		LogLevel  string `json:"log_level"`
+		// Base URL of another Discord API, like the fake server of the end-to-end tests
+		DiscordURL string `json:"discord_url"`
 	}
+	// Audit log of the moderation events
+	auditLog = audit.NewLogger()
 )
 
 func init() {
 	// Initialize the map
 	muteData.MutedUsers = make(map[string]MuteInfo)
+	muteData.Appeals = make(map[string]Appeal)
+	muteData.Juries = make(map[string]Jury)
+	muteData.VoteBans = make(map[string]map[string]VoteBan)
+	muteData.Actions = make(map[string]PendingAction)
+}
 
-	// Load configuration
-	configFile, err := os.ReadFile("config.json")
+// loadConfig reads config.json, opens the audit log and loads the stored data
+func loadConfig() {
+	err := readConfig()
 	if err != nil {
-		log.Fatalf("Error reading configuration file: %v", err)
+		fatal("Error reading configuration file", "error", err)
 	}
 
-	err = json.Unmarshal(configFile, &config)
+	err = setupLogging()
 	if err != nil {
-		log.Fatalf("Error parsing configuration file: %v", err)
+		fatal("Error configuring the logs", "error", err)
 	}
 
-	// Load existing data if it exists
+	auditLog, err = openAuditLog()
+	if err != nil {
+		fatal("Error configuring the audit log", "error", err)
+	}
+
+	// Load existing data if it exists. The guild settings go first, they are needed to rebuild damaged mute data.
+	loadGuildConfigs()
 	loadMuteData()
+	loadHistory()
+}
+
+func readConfig() error {
+	configFile, err := os.ReadFile("config.json")
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(configFile, &config)
 }
 
 func main() {
+	// Subcommands don't need the configuration
+	if len(os.Args) > 1 {
+		os.Exit(runSubcommand(os.Args[1], os.Args[2:]))
+	}
+
+	loadConfig()
 	if config.Token == "" || config.Token == "TU_TOKEN_AQUI" {
-		log.Fatalf("Token not configured. Edit the config.json file.")
+		fatal("Token not configured. Edit the config.json file.")
 	}
 
-	// Configure logger
-	log.SetFlags(log.LstdFlags | log.Lshortfile)
-	log.Println("Starting bot...")
+	slog.Info("Starting bot...")
+	if config.DiscordURL != "" {
+		setDiscordURL(config.DiscordURL)
+		slog.Warn("Using another Discord API", "url", config.DiscordURL)
+	}
 
 	dg, err := discordgo.New("Bot " + config.Token)
 	if err != nil {
-		log.Fatalf("Error logging in to Discord: %v", err)
+		fatal("Error logging in to Discord", "error", err)
+	}
+	session = discordSession{dg}
+
+	// Clean up old log files in the background
+	auditLog.StartJanitor(logRetentionPolicy())
+
+	updateMuteMetrics()
+	var server *http.Server
+	if config.HTTPAddr != "" {
+		server = startHTTPServer(config.HTTPAddr)
 	}
 
 	// Update intents to include necessary permissions
@@ -78,51 +187,74 @@ func main() {
 		discordgo.IntentsMessageContent
 
 	// Register handlers with more logs
-	dg.AddHandler(func(s *discordgo.Session, r *discordgo.Ready) {
-		log.Printf("Bot is ready! Connected as: %v#%v", s.State.User.Username, s.State.User.Discriminator)
-		log.Printf("Bot ID: %v", s.State.User.ID)
-		log.Printf("Servers the bot belongs to: %d", len(s.State.Guilds))
-		for _, g := range s.State.Guilds {
-			log.Printf("- Server: %s (ID: %s)", g.Name, g.ID)
-		}
-	})
-
-	dg.AddHandler(voiceStateUpdate)
-	dg.AddHandler(messageCreate)
+	// The handlers take the Discord interface, see discord.go
+	dg.AddHandler(func(_ *discordgo.Session, r *discordgo.Ready) { ready(session, r) })
+	dg.AddHandler(func(_ *discordgo.Session, v *discordgo.VoiceStateUpdate) { voiceStateUpdate(session, v) })
+	dg.AddHandler(func(_ *discordgo.Session, m *discordgo.MessageCreate) { messageCreate(session, m) })
+	dg.AddHandler(func(_ *discordgo.Session, i *discordgo.InteractionCreate) { interactionCreate(session, i) })
 
 	// Add error handler
 	dg.AddHandler(func(s *discordgo.Session, e *discordgo.Connect) {
-		log.Println("Connected to Discord")
+		gatewayConnects.Inc()
+		markConnected(true)
+		slog.Info("Connected to Discord")
 	})
 
 	dg.AddHandler(func(s *discordgo.Session, e *discordgo.Disconnect) {
-		log.Println("Disconnected from Discord")
+		gatewayDisconnects.Inc()
+		markConnected(false)
+		slog.Warn("Disconnected from Discord")
 	})
 
 	err = dg.Open()
 	if err != nil {
-		log.Fatalf("Error logging in to Discord: %v", err)
+		fatal("Error logging in to Discord", "error", err)
 	}
 
-	fmt.Println("✅ The Ninicracia is in operation. Press Ctrl+C to exit")
+	slog.Info("✅ The Ninicracia is in operation. Press Ctrl+C to exit")
 	stop := make(chan os.Signal, 1)
-	signal.Notify(stop, os.Interrupt)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
 	<-stop
 
-	err = dg.Close()
+	shutdown(dg, server)
+}
+
+func ready(s Discord, r *discordgo.Ready) {
+	slog.Info("Bot is ready!", "username", s.BotUser().Username, "user_id", s.BotUser().ID,
+		"guilds", len(s.StateGuilds()))
+	for _, g := range s.StateGuilds() {
+		slog.Info("Server the bot belongs to", "guild_id", g.ID, "guild", g.Name)
+	}
+
+	// Register slash commands
+	_, err := s.ApplicationCommandCreate(s.BotUser().ID, "", configCommand())
 	if err != nil {
-		return
+		slog.Error("Error registering /config command", "error", err)
 	}
+
+	if beginWork() {
+		resumeTimers(s)
+		endWork()
+	}
+	markReady()
 }
 
-func messageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
+func messageCreate(s Discord, m *discordgo.MessageCreate) {
 	// Ignore messages from the bot
 	if m.Author.Bot {
 		return
 	}
+	if !beginWork() {
+		return
+	}
+	defer endWork()
 
-	// Log received message
-	// log.Printf("Message received - Author: %s, Content: %s, Channel: %s", m.Author.Username, m.Content, m.ChannelID)
+	// Measure the time of the known commands
+	if fields := strings.Fields(m.Content); len(fields) > 0 && knownCommands[fields[0]] {
+		slog.Debug("Command received", "command", fields[0], "guild_id", m.GuildID, "channel_id", m.ChannelID,
+			"user_id", m.Author.ID)
+		defer observeCommand(fields[0], time.Now())
+	}
 
 	// COMMANDS:
 	switch {
@@ -133,10 +265,10 @@ func messageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
 		var servidores strings.Builder
 		servidores.WriteString("🤖 **Servers to which I belong:**\n\n")
 
-		if len(s.State.Guilds) == 0 {
+		if len(s.StateGuilds()) == 0 {
 			servidores.WriteString("I'm not in any server. Invite me using the generated link!\n")
 		} else {
-			for i, g := range s.State.Guilds {
+			for i, g := range s.StateGuilds() {
 				guild, err := s.Guild(g.ID)
 				if err != nil {
 					servidores.WriteString(fmt.Sprintf("%d. **%s** (ID: %s) - Error getting details\n", i+1, g.Name, g.ID))
@@ -147,7 +279,7 @@ func messageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
 				servidores.WriteString(fmt.Sprintf("   - Region: %s\n", guild.Region))
 
 				// Try to get the bot's role
-				member, err := s.GuildMember(g.ID, s.State.User.ID)
+				member, err := s.GuildMember(g.ID, s.BotUser().ID)
 				if err != nil {
 					servidores.WriteString("   - Roles: Error getting roles\n")
 				} else if len(member.Roles) == 0 {
@@ -155,7 +287,7 @@ func messageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
 				} else {
 					servidores.WriteString("   - Roles: ")
 					for j, roleID := range member.Roles {
-						role, err := s.State.Role(g.ID, roleID)
+						role, err := s.StateRole(g.ID, roleID)
 						if err != nil {
 							servidores.WriteString(fmt.Sprintf("Role %s (error), ", roleID))
 						} else {
@@ -189,18 +321,16 @@ func messageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
 			m.Author.ID,
 			m.ChannelID,
 			m.GuildID,
-			s.Identify.Intents,
+			s.Intents(),
 			len(muteData.MutedUsers))
 		s.ChannelMessageSend(m.ChannelID, debugInfo)
 	case strings.HasPrefix(m.Content, "!mute "):
-		log.Printf("!mute command detected")
 		if len(m.Mentions) == 0 {
 			s.ChannelMessageSend(m.ChannelID, "⚠️ You must mention a user with @ to vote to mute them. Example: `!mute @pablito`")
 			return
 		}
 		handleMute(s, m, m.Mentions[0])
 	case strings.HasPrefix(m.Content, "!muteinfo"):
-		fmt.Println("✅ muteinfo command")
 		if len(m.Mentions) == 0 {
 			// Show all users with active votes
 			handleMuteInfoAll(s, m)
@@ -210,6 +340,75 @@ func messageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
 		}
 	case m.Content == "!mutestatus":
 		handleMuteStatus(s, m)
+	case m.Content == "!appeals" || strings.HasPrefix(m.Content, "!appeals "):
+		if !requirePermission(s, m, "appeals") {
+			return
+		}
+		handleAppealList(s, m)
+	case m.Content == "!appeal" || strings.HasPrefix(m.Content, "!appeal "):
+		handleAppeal(s, m)
+	case m.Content == "!config" || strings.HasPrefix(m.Content, "!config "):
+		if !requirePermission(s, m, "config") {
+			return
+		}
+		handleConfig(s, m)
+	case strings.HasPrefix(m.Content, "!forcemute "):
+		if len(m.Mentions) == 0 {
+			s.ChannelMessageSend(m.ChannelID, "❌ Please mention a user to mute. Example: `!forcemute @user 30m spamming`")
+			return
+		}
+		if !requirePermission(s, m, "forcemute") {
+			return
+		}
+		handleForceMute(s, m, m.Mentions[0])
+	case strings.HasPrefix(m.Content, "!forceunmute "):
+		if len(m.Mentions) == 0 {
+			s.ChannelMessageSend(m.ChannelID, "❌ Please mention a user to unmute. Example: `!forceunmute @user`")
+			return
+		}
+		if !requirePermission(s, m, "forceunmute") {
+			return
+		}
+		handleForceUnmute(s, m, m.Mentions[0])
+	case strings.HasPrefix(m.Content, "!voteban "):
+		if len(m.Mentions) == 0 {
+			s.ChannelMessageSend(m.ChannelID, "❌ Please mention a user to ban from voting. Example: `!voteban @user 7d`")
+			return
+		}
+		if !requirePermission(s, m, "voteban") {
+			return
+		}
+		handleVoteBan(s, m, m.Mentions[0])
+	case strings.HasPrefix(m.Content, "!voteunban "):
+		if len(m.Mentions) == 0 {
+			s.ChannelMessageSend(m.ChannelID, "❌ Please mention a user to allow to vote again. Example: `!voteunban @user`")
+			return
+		}
+		if !requirePermission(s, m, "voteban") {
+			return
+		}
+		handleVoteUnban(s, m, m.Mentions[0])
+	case m.Content == "!deadletters" || strings.HasPrefix(m.Content, "!deadletters "):
+		if !requirePermission(s, m, "deadletters") {
+			return
+		}
+		handleDeadLetters(s, m)
+	case m.Content == "!votebans":
+		if !requirePermission(s, m, "voteban") {
+			return
+		}
+		handleVoteBanList(s, m)
+	case strings.HasPrefix(m.Content, "!history"):
+		if len(m.Mentions) == 0 {
+			s.ChannelMessageSend(m.ChannelID, "❌ Please mention a user to see their history. Example: `!history @user`")
+			return
+		}
+		if !requirePermission(s, m, "history") {
+			return
+		}
+		handleHistory(s, m, m.Mentions[0])
+	case m.Content == "!stats" || strings.HasPrefix(m.Content, "!stats "):
+		handleStats(s, m)
 	case m.Content == "!help":
 		handleHelp(s, m)
 	case strings.HasPrefix(m.Content, "!clean"):
@@ -218,62 +417,113 @@ func messageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
 			return
 		}
 
-		// Verify if the message author is an administrator
-		member, err := s.GuildMember(m.GuildID, m.Author.ID)
-		if err != nil {
-			s.ChannelMessageSend(m.ChannelID, "❌ Error verifying administrator permissions")
-			log.Printf("Error verifying administrator permissions: %v", err)
+		// Verify if the message author can clean votes
+		if !requirePermission(s, m, "clean") {
 			return
 		}
 
-		// Verify if the user has administrator permissions
-		hasAdminPerms := false
+		// Process the mention
+		target := m.Mentions[0]
+		handleClean(s, m, target)
+	}
+}
 
-		// Get server roles
-		guildRoles, err := s.GuildRoles(m.GuildID)
-		if err != nil {
-			s.ChannelMessageSend(m.ChannelID, "❌ Error verifying administrator permissions")
-			log.Printf("Error getting server roles: %v", err)
-			return
-		}
+func interactionCreate(s Discord, i *discordgo.InteractionCreate) {
+	if !beginWork() {
+		return
+	}
+	defer endWork()
 
-		for _, roleID := range member.Roles {
-			for _, guildRole := range guildRoles {
-				if guildRole.ID == roleID {
-					// Verify if the role has administrator permissions
-					if guildRole.Permissions&discordgo.PermissionAdministrator == discordgo.PermissionAdministrator {
-						hasAdminPerms = true
-						break
-					}
-				}
-			}
-			if hasAdminPerms {
-				break
-			}
+	switch i.Type {
+	case discordgo.InteractionApplicationCommand:
+		if i.ApplicationCommandData().Name == "config" {
+			defer observeCommand("/config", time.Now())
+			handleConfigSlash(s, i)
+		}
+	case discordgo.InteractionMessageComponent:
+		customID := i.MessageComponentData().CustomID
+		switch {
+		case strings.HasPrefix(customID, "appeal_open:"):
+			handleAppealOpen(s, i, strings.TrimPrefix(customID, "appeal_open:"))
+		case strings.HasPrefix(customID, "appeal_accept:"):
+			handleAppealResolve(s, i, strings.TrimPrefix(customID, "appeal_accept:"), true)
+		case strings.HasPrefix(customID, "appeal_reject:"):
+			handleAppealResolve(s, i, strings.TrimPrefix(customID, "appeal_reject:"), false)
+		case strings.HasPrefix(customID, "history:"):
+			handleHistoryPage(s, i, strings.TrimPrefix(customID, "history:"))
+		case strings.HasPrefix(customID, "jury_confirm:"):
+			handleJuryVote(s, i, strings.TrimPrefix(customID, "jury_confirm:"), true)
+		case strings.HasPrefix(customID, "jury_reject:"):
+			handleJuryVote(s, i, strings.TrimPrefix(customID, "jury_reject:"), false)
 		}
+	case discordgo.InteractionModalSubmit:
+		customID := i.ModalSubmitData().CustomID
+		if strings.HasPrefix(customID, "appeal_modal:") {
+			handleAppealSubmit(s, i, strings.TrimPrefix(customID, "appeal_modal:"))
+		}
+	}
+}
 
-		if !hasAdminPerms {
-			s.ChannelMessageSend(m.ChannelID, "❌ You don't have administrator permissions to use this command")
-			return
+// interactionUser returns the user who triggered the interaction, both in guilds and DMs
+func interactionUser(i *discordgo.InteractionCreate) *discordgo.User {
+	if i.Member != nil {
+		return i.Member.User
+	}
+	return i.User
+}
+
+// splitMessage splits a text in chunks that fit in a Discord message, breaking at line ends
+func splitMessage(text string) []string {
+	const maxLength = 2000
+
+	var chunks []string
+	for len(text) > maxLength {
+		cut := strings.LastIndex(text[:maxLength], "\n")
+		if cut <= 0 {
+			cut = maxLength
 		}
+		chunks = append(chunks, text[:cut])
+		text = strings.TrimPrefix(text[cut:], "\n")
+	}
+	return append(chunks, text)
+}
 
-		// Process the mention
-		target := m.Mentions[0]
-		handleClean(s, m, target)
+// respondEphemeral answers an interaction with a message only the user can see
+func respondEphemeral(s Discord, i *discordgo.InteractionCreate, content string) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		slog.Error("Error responding to interaction", "guild_id", i.GuildID, "error", err)
 	}
 }
 
-func handleMute(s *discordgo.Session, m *discordgo.MessageCreate, target *discordgo.User) {
+func handleMute(s Discord, m *discordgo.MessageCreate, target *discordgo.User) {
 	// Anti-MRPABLO checks
 	// Don't allow voting against oneself
 	if target.ID == m.Author.ID {
-		s.ChannelMessageSend(m.ChannelID, "⚠️ You can't vote to mute yourself.")
+		s.ChannelMessageSend(m.ChannelID, translate(m.GuildID, "⚠️ You can't vote to mute yourself."))
 		return
 	}
 
 	// Don't allow voting against the bot
 	if target.Bot {
-		s.ChannelMessageSend(m.ChannelID, "⚠️ You can't vote to mute a bot.")
+		s.ChannelMessageSend(m.ChannelID, translate(m.GuildID, "⚠️ You can't vote to mute a bot."))
+		return
+	}
+
+	// Don't allow voting to banned voters
+	if ban, banned := activeVoteBan(m.GuildID, m.Author.ID); banned {
+		if ban.Until.IsZero() {
+			s.ChannelMessageSend(m.ChannelID, translate(m.GuildID, "🚫 You have been banned from voting in this server."))
+		} else {
+			s.ChannelMessageSend(m.ChannelID, translate(m.GuildID, "🚫 You have been banned from voting in this server. The ban ends in: %s",
+				timeUntil(ban.Until).Round(time.Minute)))
+		}
 		return
 	}
 
@@ -283,89 +533,207 @@ func handleMute(s *discordgo.Session, m *discordgo.MessageCreate, target *discor
 			MutedBy: make(map[string]time.Time),
 		}
 	}
+	muteInfo.GuildID = m.GuildID
 
 	// If already muted, inform and exit. Don't get ahead of yourself...
-	if muteInfo.IsGloballyMuted && time.Now().Before(muteInfo.MuteExpiry) {
-		timeLeft := time.Until(muteInfo.MuteExpiry).Round(time.Second)
-		s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("🔇 %s is already muted in voice channels. The mute will end in: %s",
+	if muteInfo.IsGloballyMuted && clock.Now().Before(muteInfo.MuteExpiry) {
+		timeLeft := timeUntil(muteInfo.MuteExpiry).Round(time.Second)
+		s.ChannelMessageSend(m.ChannelID, translate(m.GuildID, "🔇 %s is already muted in voice channels. The mute will end in: %s",
 			target.Username, timeLeft))
 		return
 	}
 
+	// Don't allow voting against immune roles
+	settings := guildSettings(m.GuildID)
+	if len(settings.ImmuneRoles) > 0 {
+		member, err := s.GuildMember(m.GuildID, target.ID)
+		if err != nil {
+			slog.Error("Error getting member", "guild_id", m.GuildID, "user_id", target.ID, "error", err)
+			s.ChannelMessageSend(m.ChannelID, translate(m.GuildID, "❌ Error getting member information."))
+			return
+		}
+		for _, roleID := range member.Roles {
+			for _, immuneRoleID := range settings.ImmuneRoles {
+				if roleID == immuneRoleID {
+					s.ChannelMessageSend(m.ChannelID, translate(m.GuildID, "🛡️ %s has an immune role and can't be voted.", target.Username))
+					return
+				}
+			}
+		}
+	}
+
+	// Votes are closed while a jury decides
+	if jury, deliberating := pendingJury(m.GuildID, target.ID); deliberating {
+		s.ChannelMessageSend(m.ChannelID, translate(m.GuildID, "⚖️ A jury is already deciding whether to mute %s. The verdict will be known in: %s",
+			target.Username, timeUntil(jury.Deadline).Round(time.Second)))
+		return
+	}
+
 	// Clean expired votes before proceeding
 	cleanExpiredVotes(&muteInfo)
 
 	// Verify if the user has already voted and their vote hasn't expired
-	if expiry, hasVoted := muteInfo.MutedBy[m.Author.ID]; hasVoted && time.Now().Before(expiry) {
-		s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("⚠️ You've already voted to mute %s. Your vote expires in: %s",
-			target.Username, time.Until(expiry).Round(time.Minute).String()))
+	if expiry, hasVoted := muteInfo.MutedBy[m.Author.ID]; hasVoted && clock.Now().Before(expiry) {
+		s.ChannelMessageSend(m.ChannelID, translate(m.GuildID, "⚠️ You've already voted to mute %s. Your vote expires in: %s",
+			target.Username, timeUntil(expiry).Round(time.Minute).String()))
 		return
 	}
 
 	// Register new vote
-	muteInfo.MutedBy[m.Author.ID] = time.Now().Add(VOTE_DURATION)
+	muteInfo.MutedBy[m.Author.ID] = clock.Now().Add(settings.VoteDuration())
 
 	// Count active votes
 	activeVotes := len(muteInfo.MutedBy)
 
 	// Register vote in log
-	logAction("VOTE", m.Author.Username, target.Username, activeVotes, m.GuildID)
+	recordEvent(audit.Event{
+		Type:        "VOTE",
+		GuildID:     m.GuildID,
+		ChannelID:   m.ChannelID,
+		MessageID:   m.ID,
+		InitiatorID: m.Author.ID,
+		Initiator:   m.Author.Username,
+		TargetID:    target.ID,
+		Target:      target.Username,
+		Votes:       activeVotes,
+		ExpiresAt:   muteInfo.MutedBy[m.Author.ID],
+	})
 
 	// Verify if the threshold of votes is reached and the user isn't globally muted
-	if activeVotes >= VOTES_NEEDED && !muteInfo.IsGloballyMuted {
-		// Find the user in all voice channels of the server
-		guild, err := s.State.Guild(m.GuildID)
-		if err != nil {
-			log.Printf("Error getting server information: %v", err)
-			s.ChannelMessageSend(m.ChannelID, "❌ Error getting server information.")
-			return
+	if activeVotes >= settings.VotesNeeded && !muteInfo.IsGloballyMuted {
+		// In jury mode the mute waits for the verdict of the jury
+		if !settings.JuryMode || !startJury(s, m.GuildID, m.ChannelID, target, muteInfo) {
+			request := muteRequest{
+				GuildID:     m.GuildID,
+				ChannelID:   m.ChannelID,
+				Target:      target,
+				Action:      "MUTE",
+				InitiatorID: m.Author.ID,
+				Initiator:   m.Author.Username,
+				MessageID:   m.ID,
+				Duration:    settings.MuteDuration(),
+			}
+			if !applyMute(s, request, &muteInfo) {
+				return
+			}
 		}
+	} else {
+		s.ChannelMessageSend(m.ChannelID, translate(m.GuildID, "✅ Vote registered against %s. Current votes: %d/%d\nYour vote expires in %d minutes.",
+			target.Username, activeVotes, settings.VotesNeeded, settings.VoteMinutes))
+	}
 
-		// Try to mute the user (only affects if they're in a voice channel)
-		err = s.GuildMemberMute(m.GuildID, target.ID, true)
-		if err != nil {
-			log.Printf("Error muting %s: %v", target.Username, err)
-			s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("❌ Error muting %s. It's possible they're not in a voice channel.", target.Username))
-			return
-		}
+	muteData.MutedUsers[target.ID] = muteInfo
+	saveMuteData()
+}
 
-		muteInfo.IsGloballyMuted = true
-		muteInfo.MuteExpiry = time.Now().Add(MUTE_DURATION)
+// muteRequest describes a mute triggered by votes, a jury or a moderator
+type muteRequest struct {
+	GuildID   string
+	ChannelID string
+	Target    *discordgo.User
+	MessageID string
+	Action    string // MUTE or FORCE_MUTE
+	// Empty for the jury
+	InitiatorID string
+	Initiator   string
+	Duration    time.Duration
+	Reason      string
+}
 
-		// Register mute in log
-		logAction("MUTE", m.Author.Username, target.Username, activeVotes, m.GuildID)
+// applyMute mutes the target in voice channels, schedules the unmute and announces it in the channel.
+// Returns false if the mute couldn't be applied.
+func applyMute(s Discord, request muteRequest, muteInfo *MuteInfo) bool {
+	guildID, channelID, target := request.GuildID, request.ChannelID, request.Target
 
-		// Schedule automatic unmute
-		time.AfterFunc(MUTE_DURATION, func() {
-			unmuteUser(s, m.GuildID, target.ID)
-		})
+	// Find the user in all voice channels of the server
+	guild, err := s.StateGuild(guildID)
+	if err != nil {
+		slog.Error("Error getting server information", "guild_id", guildID, "error", err)
+		s.ChannelMessageSend(channelID, translate(guildID, "❌ Error getting server information."))
+		return false
+	}
 
-		// Verify if the user is currently in a voice channel
-		isInVoiceChannel := false
-		for _, vs := range guild.VoiceStates {
-			if vs.UserID == target.ID {
-				isInVoiceChannel = true
-				break
-			}
-		}
+	// A server mute the bot didn't apply is kept, and not lifted when the mute ends
+	if !muteInfo.IsGloballyMuted {
+		muteInfo.ManualMute = mutedByOthers(s, guildID, target.ID)
+	}
 
-		if isInVoiceChannel {
-			s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("🔇 %s has been muted in voice channels for %d minutes.",
-				target.Username, int(MUTE_DURATION.Minutes())))
-		} else {
-			s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("🔇 %s will be muted when they join a voice channel. The mute will last %d minutes.",
-				target.Username, int(MUTE_DURATION.Minutes())))
+	// Try to mute the user (only affects if they're in a voice channel)
+	var muteErr error
+	if !muteInfo.ManualMute {
+		muteErr = guildMemberMute(s, guildID, target.ID, true)
+	}
+	if err = muteErr; muteChangeDeferred(err) {
+		err = nil
+	}
+	if err != nil {
+		slog.Error("Error muting user", "guild_id", guildID, "user_id", target.ID, "error", err)
+		s.ChannelMessageSend(channelID, translate(guildID, "❌ Error muting %s. It's possible they're not in a voice channel.", target.Username))
+		return false
+	}
+
+	settings := guildSettings(guildID)
+	muteInfo.GuildID = guildID
+	muteInfo.IsGloballyMuted = true
+	muteInfo.MuteExpiry = clock.Now().Add(request.Duration)
+	muteInfo.Reason = request.Reason
+	timedOut := applyTimeout(s, guildID, target.ID, muteInfo)
+
+	// Register mute in log
+	recordEvent(audit.Event{
+		Type:        request.Action,
+		GuildID:     guildID,
+		ChannelID:   channelID,
+		MessageID:   request.MessageID,
+		InitiatorID: request.InitiatorID,
+		Initiator:   request.Initiator,
+		TargetID:    target.ID,
+		Target:      target.Username,
+		Votes:       len(muteInfo.MutedBy),
+		Voters:      voterIDs(*muteInfo),
+		ExpiresAt:   muteInfo.MuteExpiry,
+		Reason:      request.Reason,
+		ManualMute:  muteInfo.ManualMute,
+		TimedOut:    muteInfo.TimedOut,
+	})
+
+	// Schedule automatic unmute
+	scheduleUnmute(s, guildID, target.ID, request.Duration)
+
+	// Verify if the user is currently in a voice channel
+	isInVoiceChannel := false
+	for _, vs := range guild.VoiceStates {
+		if vs.UserID == target.ID {
+			isInVoiceChannel = true
+			break
 		}
+	}
+
+	// Announce in the configured channel, or where the mute was triggered
+	if settings.AnnounceChannel != "" {
+		channelID = settings.AnnounceChannel
+	}
+
+	minutes := int(request.Duration.Minutes())
+	var announcement string
+	if isInVoiceChannel {
+		announcement = translate(guildID, "🔇 %s has been muted in voice channels for %d minutes.", target.Username, minutes)
 	} else {
-		s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("✅ Vote registered against %s. Current votes: %d/%d\nYour vote expires in %d minutes.",
-			target.Username, activeVotes, VOTES_NEEDED, int(VOTE_DURATION.Minutes())))
+		announcement = translate(guildID, "🔇 %s will be muted when they join a voice channel. The mute will last %d minutes.",
+			target.Username, minutes)
 	}
+	if request.Reason != "" {
+		announcement += translate(guildID, "\nReason: %s", request.Reason)
+	}
+	announcement += pendingNote(muteErr) + timeoutNote(guildID, *muteInfo, timedOut)
+	s.ChannelMessageSend(channelID, announcement)
 
-	muteData.MutedUsers[target.ID] = muteInfo
-	saveMuteData()
+	// Let the user know they can appeal
+	notifyMuted(s, guild, target, request)
+	return true
 }
 
-func handleMuteInfo(s *discordgo.Session, m *discordgo.MessageCreate, targetID string) {
+func handleMuteInfo(s Discord, m *discordgo.MessageCreate, targetID string) {
 	muteInfo, exists := muteData.MutedUsers[targetID]
 	if !exists || len(muteInfo.MutedBy) == 0 {
 		s.ChannelMessageSend(m.ChannelID, "📊 No active votes for this user.")
@@ -375,7 +743,7 @@ func handleMuteInfo(s *discordgo.Session, m *discordgo.MessageCreate, targetID s
 	// Get user info
 	user, err := s.User(targetID)
 	if err != nil {
-		log.Printf("Error getting info for user %s: %v", targetID, err)
+		slog.Error("Error getting user info", "guild_id", m.GuildID, "user_id", targetID, "error", err)
 		user = &discordgo.User{Username: "Unknown user"}
 	}
 
@@ -392,7 +760,7 @@ func handleMuteInfo(s *discordgo.Session, m *discordgo.MessageCreate, targetID s
 	// Create message with information
 	var msg strings.Builder
 	msg.WriteString(fmt.Sprintf("📊 Active votes to mute %s (%d/%d):\n```\n",
-		user.Username, len(muteInfo.MutedBy), VOTES_NEEDED))
+		user.Username, len(muteInfo.MutedBy), guildSettings(m.GuildID).VotesNeeded))
 
 	for voterID, expiry := range muteInfo.MutedBy {
 		// Try to get the username of the voter
@@ -402,13 +770,13 @@ func handleMuteInfo(s *discordgo.Session, m *discordgo.MessageCreate, targetID s
 			username = voter.Username
 		}
 
-		timeLeft := time.Until(expiry).Round(time.Second)
+		timeLeft := timeUntil(expiry).Round(time.Second)
 		msg.WriteString(fmt.Sprintf("%s (expires in: %s)\n", username, timeLeft))
 	}
 	msg.WriteString("```")
 
 	if muteInfo.IsGloballyMuted {
-		timeLeft := time.Until(muteInfo.MuteExpiry).Round(time.Second)
+		timeLeft := timeUntil(muteInfo.MuteExpiry).Round(time.Second)
 		if timeLeft > 0 {
 			msg.WriteString(fmt.Sprintf("\n🔇 %s is globally muted. Time remaining: %s", user.Username, timeLeft))
 		}
@@ -417,7 +785,7 @@ func handleMuteInfo(s *discordgo.Session, m *discordgo.MessageCreate, targetID s
 	s.ChannelMessageSend(m.ChannelID, msg.String())
 }
 
-func handleMuteInfoAll(s *discordgo.Session, m *discordgo.MessageCreate) {
+func handleMuteInfoAll(s Discord, m *discordgo.MessageCreate) {
 	// Verify if there are users with votes
 	if len(muteData.MutedUsers) == 0 {
 		s.ChannelMessageSend(m.ChannelID, "📊 No active votes for any user.")
@@ -442,6 +810,7 @@ func handleMuteInfoAll(s *discordgo.Session, m *discordgo.MessageCreate) {
 	}
 
 	// Create message with all users who have votes
+	votesNeeded := guildSettings(m.GuildID).VotesNeeded
 	var msg strings.Builder
 	msg.WriteString("📊 **Users with active votes:**\n\n")
 
@@ -454,17 +823,17 @@ func handleMuteInfoAll(s *discordgo.Session, m *discordgo.MessageCreate) {
 		}
 
 		if muteInfo.IsGloballyMuted {
-			timeLeft := time.Until(muteInfo.MuteExpiry).Round(time.Second)
+			timeLeft := timeUntil(muteInfo.MuteExpiry).Round(time.Second)
 			if timeLeft > 0 {
 				msg.WriteString(fmt.Sprintf("🔇 **%s**: Muted in voice for %s more - Votes: %d/%d\n",
-					username, timeLeft, len(muteInfo.MutedBy), VOTES_NEEDED))
+					username, timeLeft, len(muteInfo.MutedBy), votesNeeded))
 			} else {
 				msg.WriteString(fmt.Sprintf("📊 **%s**: Votes: %d/%d\n",
-					username, len(muteInfo.MutedBy), VOTES_NEEDED))
+					username, len(muteInfo.MutedBy), votesNeeded))
 			}
 		} else {
 			msg.WriteString(fmt.Sprintf("📊 **%s**: Votes: %d/%d\n",
-				username, len(muteInfo.MutedBy), VOTES_NEEDED))
+				username, len(muteInfo.MutedBy), votesNeeded))
 		}
 	}
 
@@ -472,32 +841,51 @@ func handleMuteInfoAll(s *discordgo.Session, m *discordgo.MessageCreate) {
 	s.ChannelMessageSend(m.ChannelID, msg.String())
 }
 
-func handleMuteStatus(s *discordgo.Session, m *discordgo.MessageCreate) {
+func handleMuteStatus(s Discord, m *discordgo.MessageCreate) {
 	var msg strings.Builder
 	msg.WriteString("📋 **Mute system status:**\n")
-	msg.WriteString(fmt.Sprintf("- Votes needed: **%d**\n", VOTES_NEEDED))
-	msg.WriteString(fmt.Sprintf("- Vote duration: **%d minutes**\n", int(VOTE_DURATION.Minutes())))
-	msg.WriteString(fmt.Sprintf("- Mute duration: **%d minutes**\n", int(MUTE_DURATION.Minutes())))
+	settings := guildSettings(m.GuildID)
+	msg.WriteString(fmt.Sprintf("- Votes needed: **%d**\n", settings.VotesNeeded))
+	msg.WriteString(fmt.Sprintf("- Vote duration: **%d minutes**\n", settings.VoteMinutes))
+	msg.WriteString(fmt.Sprintf("- Mute duration: **%d minutes**\n", settings.MuteMinutes))
+	if settings.JuryMode {
+		msg.WriteString(fmt.Sprintf("- Jury mode: **enabled** (%d jurors, %d minutes to decide)\n",
+			settings.JurySize, settings.JuryMinutes))
+	} else {
+		msg.WriteString("- Jury mode: **disabled**\n")
+	}
 
 	s.ChannelMessageSend(m.ChannelID, msg.String())
 }
 
-func handleHelp(s *discordgo.Session, m *discordgo.MessageCreate) {
+func handleHelp(s Discord, m *discordgo.MessageCreate) {
+	settings := guildSettings(m.GuildID)
 	help := "📌 **Voice Mute Commands:**\n\n" +
 		"**!mute @user** - Vote to mute the mentioned user in voice channels\n" +
 		"**!muteinfo** - Show all users with active votes\n" +
 		"**!muteinfo @user** - Show votes for a specific user\n" +
 		"**!mutestatus** - Show mute system configuration\n" +
-		"**!clean @user** - (Only administrators) Remove all votes against a user\n" +
+		"**!stats [period]** - Show statistics and leaderboards of the server (e.g. `24h`, `30d`, `all`)\n" +
+		"**!clean @user** - (Only moderators) Remove all votes against a user\n" +
+		"**!appeal <text>** - Appeal your current mute to the moderators\n" +
+		"**!appeals [all]** - (Only moderators) List pending appeals, or all of them\n" +
+		"**!forcemute @user [duration] [reason]** - (Only moderators) Mute a user without votes\n" +
+		"**!forceunmute @user** - (Only moderators) Lift the mute of a user and discard their votes\n" +
+		"**!voteban @user [duration]** - (Only moderators) Ban a user from voting, permanently if no duration is given\n" +
+		"**!voteunban @user** - (Only moderators) Allow a user to vote again\n" +
+		"**!votebans** - (Only moderators) List the users banned from voting\n" +
+		"**!history @user** - (Only moderators) Show the moderation history of a user\n" +
+		"**!deadletters [retry <id>|clear]** - (Only moderators) List the mutes and unmutes Discord failed to apply\n" +
+		"**!config** - (Only administrators) View or change the configuration of the server\n" +
 		"**!help** - Show this help message\n\n" +
 		fmt.Sprintf("**%d votes** are needed to mute a user for **%d minutes**. The mute only affects voice channels.",
-			VOTES_NEEDED, int(MUTE_DURATION.Minutes()))
+			settings.VotesNeeded, settings.MuteMinutes)
 
 	s.ChannelMessageSend(m.ChannelID, help)
 }
 
 func cleanExpiredVotes(muteInfo *MuteInfo) {
-	now := time.Now()
+	now := clock.Now()
 	for user, expiry := range muteInfo.MutedBy {
 		if now.After(expiry) {
 			delete(muteInfo.MutedBy, user)
@@ -505,23 +893,35 @@ func cleanExpiredVotes(muteInfo *MuteInfo) {
 	}
 }
 
-func unmuteUser(s *discordgo.Session, guildID string, userID string) {
-	muteInfo, exists := muteData.MutedUsers[userID]
-	if !exists || !muteInfo.IsGloballyMuted {
-		return
-	}
+// scheduleUnmute unmutes the user automatically when the mute expires
+func scheduleUnmute(s Discord, guildID, userID string, after time.Duration) {
+	clock.AfterFunc(after, func() {
+		// During a shutdown the next process unmutes the user
+		if !beginWork() {
+			return
+		}
+		defer endWork()
 
-	err := s.GuildMemberMute(guildID, userID, false)
-	if err != nil {
-		log.Printf("Error unmuting user %s: %v", userID, err)
+		muteInfo, exists := muteData.MutedUsers[userID]
+		if !exists || !muteInfo.IsGloballyMuted {
+			return
+		}
+
+		// The mute was replaced by a longer one, wait for it
+		if remaining := timeUntil(muteInfo.MuteExpiry); remaining > 0 {
+			scheduleUnmute(s, guildID, userID, remaining)
+			return
+		}
+
+		unmuteUser(s, guildID, userID)
+	})
+}
+
+func unmuteUser(s Discord, guildID string, userID string) {
+	if err := liftMute(s, guildID, userID); err != nil && !muteChangeDeferred(err) {
 		return
 	}
 
-	// Update user status
-	muteInfo.IsGloballyMuted = false
-	muteData.MutedUsers[userID] = muteInfo
-	saveMuteData()
-
 	// Register action in log
 	// Get user name
 	user, err := s.User(userID)
@@ -530,13 +930,54 @@ func unmuteUser(s *discordgo.Session, guildID string, userID string) {
 		username = user.Username
 	}
 
-	logAction("UNMUTE", "System", username, 0, guildID)
+	recordEvent(audit.Event{
+		Type:     "UNMUTE",
+		GuildID:  guildID,
+		TargetID: userID,
+		Target:   username,
+	})
 
-	// Log for debug
-	log.Printf("User %s unmuted automatically", userID)
+	slog.Info("User unmuted automatically", "guild_id", guildID, "user_id", userID)
 }
 
-func voiceStateUpdate(s *discordgo.Session, v *discordgo.VoiceStateUpdate) {
+// liftMute removes the voice mute and updates the user status, keeping their votes.
+// The status is also updated when the unmute was queued or dead-lettered, and that error is returned.
+func liftMute(s Discord, guildID string, userID string) error {
+	muteInfo, exists := muteData.MutedUsers[userID]
+	if !exists || !muteInfo.IsGloballyMuted {
+		return fmt.Errorf("user %s is not muted", userID)
+	}
+
+	var err error
+	if muteInfo.ManualMute {
+		slog.Info("Keeping the server mute applied by a moderator", "guild_id", guildID, "user_id", userID)
+	} else if err = guildMemberMute(s, guildID, userID, false); err != nil && !muteChangeDeferred(err) {
+		slog.Error("Error unmuting user", "guild_id", guildID, "user_id", userID, "error", err)
+		return err
+	}
+
+	endTimeout(s, guildID, userID, &muteInfo)
+
+	// Update user status
+	muteInfo.IsGloballyMuted = false
+	muteInfo.ManualMute = false
+	muteInfo.Reason = ""
+	muteData.MutedUsers[userID] = muteInfo
+	saveMuteData()
+	return err
+}
+
+func voiceStateUpdate(s Discord, v *discordgo.VoiceStateUpdate) {
+	if !beginWork() {
+		return
+	}
+	defer endWork()
+
+	// Discord only changes the mute of members in a voice channel, retry the queued change now
+	if v.ChannelID != "" {
+		retryMemberActions(s, v.GuildID, v.UserID)
+	}
+
 	// Verify if there are muted users
 	muteInfo, exists := muteData.MutedUsers[v.UserID]
 	if !exists || !muteInfo.IsGloballyMuted {
@@ -544,14 +985,27 @@ func voiceStateUpdate(s *discordgo.Session, v *discordgo.VoiceStateUpdate) {
 	}
 
 	// If the user is muted and the mute hasn't expired, ensure they're muted when they join a voice channel
-	if time.Now().Before(muteInfo.MuteExpiry) {
+	if clock.Now().Before(muteInfo.MuteExpiry) {
+		// A moderator lifted the mute by hand, don't fight them
+		if detectManualUnmute(s, v, muteInfo) {
+			return
+		}
 		// Only apply mute if the user has joined a voice channel (v.ChannelID isn't empty)
-		if v.ChannelID != "" {
-			err := s.GuildMemberMute(v.GuildID, v.UserID, true)
-			if err != nil {
-				log.Printf("Error maintaining mute for %s: %v", v.UserID, err)
-			} else {
-				log.Printf("User %s muted in voice channel, maintaining mute", v.UserID)
+		// and isn't server muted already. The update may also come from an unmute of the bot
+		// that is lifting the mute right now.
+		if v.ChannelID != "" && !v.Mute && !madeByBot(v.GuildID, v.UserID, false) {
+			err := guildMemberMute(s, v.GuildID, v.UserID, true)
+			// Queued mutes were logged by guildMemberMute
+			if err != nil && !muteChangeDeferred(err) {
+				slog.Error("Error maintaining mute", "guild_id", v.GuildID, "user_id", v.UserID, "error", err)
+			} else if err == nil {
+				slog.Info("User muted in voice channel, maintaining mute", "guild_id", v.GuildID, "user_id", v.UserID)
+				// The moderator mute is gone, the bot owns the new one
+				if muteInfo.ManualMute {
+					muteInfo.ManualMute = false
+					muteData.MutedUsers[v.UserID] = muteInfo
+					saveMuteData()
+				}
 			}
 		}
 	} else {
@@ -564,31 +1018,35 @@ func loadMuteData() {
 	data, err := os.ReadFile(muteFile)
 	if err != nil {
 		if !os.IsNotExist(err) {
-			log.Printf("Error reading mute file: %v", err)
+			slog.Error("Error reading mute file", "file", muteFile, "error", err)
 		}
 		return
 	}
 
 	err = json.Unmarshal(data, &muteData)
 	if err != nil {
-		log.Printf("Error deserializing mute data: %v", err)
+		slog.Error("Error deserializing mute data", "file", muteFile, "error", err)
+		recoverMuteData()
 	}
 }
 
 func saveMuteData() {
 	data, err := json.MarshalIndent(muteData, "", "    ")
 	if err != nil {
-		log.Printf("Error serializing mute data: %v", err)
+		slog.Error("Error serializing mute data", "error", err)
+		recordPersistence(muteFile, err)
 		return
 	}
 
 	err = os.WriteFile(muteFile, data, 0644)
 	if err != nil {
-		log.Printf("Error saving mute file: %v", err)
+		slog.Error("Error saving mute file", "file", muteFile, "error", err)
 	}
+	recordPersistence(muteFile, err)
+	updateMuteMetrics()
 }
 
-func handleClean(s *discordgo.Session, m *discordgo.MessageCreate, target *discordgo.User) {
+func handleClean(s Discord, m *discordgo.MessageCreate, target *discordgo.User) {
 	// Verify if the user is in the mute list
 	_, exists := muteData.MutedUsers[target.ID]
 	if !exists {
@@ -597,72 +1055,107 @@ func handleClean(s *discordgo.Session, m *discordgo.MessageCreate, target *disco
 	}
 
 	// If the user is muted, unmute
-	if muteData.MutedUsers[target.ID].IsGloballyMuted {
-		err := s.GuildMemberMute(m.GuildID, target.ID, false)
-		if err != nil {
-			log.Printf("Error unmuting %s: %v", target.Username, err)
-			s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("⚠️ Error unmuting %s", target.Username))
-		} else {
-			s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("🔊 %s has been unmuted by an administrator", target.Username))
-		}
+	wasMuted, err := clearMute(s, m.GuildID, target.ID)
+	if err != nil && !muteChangeDeferred(err) {
+		slog.Error("Error unmuting user", "guild_id", m.GuildID, "user_id", target.ID, "error", err)
+		s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("⚠️ Error unmuting %s", target.Username))
+	} else if wasMuted {
+		s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("🔊 %s has been unmuted by a moderator", target.Username)+pendingNote(err))
 	}
 
-	// Remove user from mute list
-	delete(muteData.MutedUsers, target.ID)
-	saveMuteData()
-
 	// Register action in log
-	logAction("CLEAN", m.Author.Username, target.Username, 0, m.GuildID)
+	recordEvent(audit.Event{
+		Type:        "CLEAN",
+		GuildID:     m.GuildID,
+		ChannelID:   m.ChannelID,
+		MessageID:   m.ID,
+		InitiatorID: m.Author.ID,
+		Initiator:   m.Author.Username,
+		TargetID:    target.ID,
+		Target:      target.Username,
+	})
 
 	s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("🧹 All votes against %s have been removed", target.Username))
 }
 
+// clearMute unmutes the user if the bot muted them and removes them from the mute list.
+// Returns errQueued or errDeadLettered if Discord didn't apply the unmute yet.
+func clearMute(s Discord, guildID, userID string) (wasMuted bool, err error) {
+	muteInfo, exists := muteData.MutedUsers[userID]
+	if !exists {
+		return false, nil
+	}
+
+	if muteInfo.IsGloballyMuted {
+		wasMuted = true
+		// Mutes applied by a moderator are left in place
+		if !muteInfo.ManualMute {
+			err = guildMemberMute(s, guildID, userID, false)
+		}
+		if err == nil || muteChangeDeferred(err) {
+			mutesLifted.Inc(guildID, "CLEAR")
+		}
+		endTimeout(s, guildID, userID, &muteInfo)
+	}
+
+	// Remove user from mute list even if the unmute failed
+	delete(muteData.MutedUsers, userID)
+	saveMuteData()
+	return wasMuted, err
+}
+
 // Logging system
-func logAction(actionType, initiator, target string, currentVotes int, guildID string) {
-	// Create logs directory if it doesn't exist
-	err := os.MkdirAll("logs", 0755)
-	if err != nil {
-		log.Printf("Error creating logs directory: %v", err)
-		return
+
+// openAuditLog creates the audit sinks selected in the configuration, the daily CSV files by default
+func openAuditLog() (*audit.Logger, error) {
+	sinks := config.AuditSinks
+	if len(sinks) == 0 {
+		sinks = []string{"csv"}
 	}
 
-	// Filename based on current date
-	currentDate := time.Now().Format("2006-01-02")
-	logFile := fmt.Sprintf("logs/%s.csv", currentDate)
+	options := audit.Options{
+		Dir:        "logs",
+		CSVVersion: config.LogCSVVersion,
+		WebhookURL: config.AuditWebhookURL,
+		// A slow or failing webhook doesn't make the bot unhealthy
+		OnWebhookError: func(err error) { auditWebhookErrors.Inc() },
+	}
 
-	// Verify if the file exists
-	fileExists := false
-	if _, err := os.Stat(logFile); err == nil {
-		fileExists = true
+	if config.LogFileMode != "" {
+		mode, err := strconv.ParseUint(config.LogFileMode, 8, 32)
+		if err != nil || mode > 0777 {
+			return nil, fmt.Errorf("invalid log_file_mode %q, use an octal mode like \"0640\"", config.LogFileMode)
+		}
+		options.FileMode = os.FileMode(mode)
 	}
 
-	// Open file in append mode
-	file, err := os.OpenFile(logFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	location, err := logLocation()
 	if err != nil {
-		log.Printf("Error opening log file: %v", err)
-		return
+		return nil, err
 	}
-	defer file.Close()
+	options.Location = location
 
-	// Write header if the file is new
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
+	return audit.New(sinks, options)
+}
 
-	if !fileExists {
-		header := []string{"Timestamp", "ActionType", "Initiator", "Target", "CurrentVotes", "GuildID"}
-		err = writer.Write(header)
-		if err != nil {
-			log.Printf("Error writing log header: %v", err)
-			return
-		}
+// logLocation returns the time zone of the log files
+func logLocation() (*time.Location, error) {
+	if config.LogTimezone == "" {
+		return time.Local, nil
 	}
 
-	// Write record
-	timestamp := time.Now().Format("2006-01-02 15:04:05")
-	record := []string{timestamp, actionType, initiator, target, fmt.Sprintf("%d", currentVotes), guildID}
-	err = writer.Write(record)
+	location, err := time.LoadLocation(config.LogTimezone)
 	if err != nil {
-		log.Printf("Error writing log record: %v", err)
-		return
+		return nil, fmt.Errorf("invalid log_timezone %q: %w", config.LogTimezone, err)
+	}
+	return location, nil
+}
+
+// logRetentionPolicy returns the retention rules of the log files from the configuration
+func logRetentionPolicy() audit.RetentionPolicy {
+	return audit.RetentionPolicy{
+		RetentionDays:     config.LogRetentionDays,
+		CompressAfterDays: config.LogCompressAfterDays,
+		MaxTotalSize:      int64(config.LogMaxTotalMB) * 1024 * 1024,
 	}
 }
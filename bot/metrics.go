@@ -0,0 +1,146 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/tu-usuario/DiscMuteBot/audit"
+	"github.com/tu-usuario/DiscMuteBot/metrics"
+)
+
+var (
+	metricsRegistry = metrics.NewRegistry()
+
+	votesCast = metricsRegistry.NewCounter("discmutebot_votes_total",
+		"Votes to mute cast", "guild")
+	mutesApplied = metricsRegistry.NewCounter("discmutebot_mutes_total",
+		"Mutes applied by votes, juries or moderators", "guild", "action")
+	mutesLifted = metricsRegistry.NewCounter("discmutebot_unmutes_total",
+		"Mutes lifted when they expired, by moderators, cleans, appeals or manual unmutes", "guild", "action")
+	muteAPIErrors = metricsRegistry.NewCounter("discmutebot_mute_api_errors_total",
+		"Errors of the Discord API when muting or unmuting members", "operation")
+	muteActionsAbandoned = metricsRegistry.NewCounter("discmutebot_mute_actions_abandoned_total",
+		"Queued mutes or unmutes moved to the dead letters after failing", "operation")
+	auditWebhookErrors = metricsRegistry.NewCounter("discmutebot_audit_webhook_errors_total",
+		"Audit events the webhook sink dropped or failed to send")
+	gatewayConnects = metricsRegistry.NewCounter("discmutebot_gateway_connects_total",
+		"Connections to the Discord gateway")
+	gatewayDisconnects = metricsRegistry.NewCounter("discmutebot_gateway_disconnects_total",
+		"Disconnections from the Discord gateway")
+	commandDuration = metricsRegistry.NewHistogram("discmutebot_command_duration_seconds",
+		"Time to handle a command", nil, "command")
+
+	_ = metricsRegistry.NewGaugeFunc("discmutebot_active_votes",
+		"Votes to mute that haven't expired", []string{"guild"}, activeVoteSamples)
+	_ = metricsRegistry.NewGaugeFunc("discmutebot_muted_users",
+		"Users currently muted by the bot", []string{"guild"}, mutedUserSamples)
+)
+
+// Commands measured by discmutebot_command_duration_seconds
+var knownCommands = map[string]bool{
+	"!ping": true, "!servers": true, "!debug": true, "!mute": true, "!muteinfo": true, "!mutestatus": true,
+	"!appeals": true, "!appeal": true, "!config": true, "!forcemute": true, "!forceunmute": true,
+	"!voteban": true, "!voteunban": true, "!votebans": true, "!history": true, "!stats": true,
+	"!help": true, "!clean": true, "!deadletters": true,
+}
+
+// Copy of the votes and mutes for the gauges, taken every time the mute data is saved,
+// so the metrics server never reads the mute data while a handler changes it
+var muteSnapshot struct {
+	sync.Mutex
+	voteExpiries map[string][]time.Time
+	mutedUsers   map[string]int
+}
+
+// updateMuteMetrics takes a new snapshot of the mute data for the gauges
+func updateMuteMetrics() {
+	voteExpiries := make(map[string][]time.Time)
+	mutedUsers := make(map[string]int)
+	for _, muteInfo := range muteData.MutedUsers {
+		for _, expiry := range muteInfo.MutedBy {
+			voteExpiries[muteInfo.GuildID] = append(voteExpiries[muteInfo.GuildID], expiry)
+		}
+		if muteInfo.IsGloballyMuted {
+			mutedUsers[muteInfo.GuildID]++
+		}
+	}
+
+	muteSnapshot.Lock()
+	defer muteSnapshot.Unlock()
+	muteSnapshot.voteExpiries = voteExpiries
+	muteSnapshot.mutedUsers = mutedUsers
+}
+
+func activeVoteSamples() []metrics.Sample {
+	muteSnapshot.Lock()
+	defer muteSnapshot.Unlock()
+
+	// Votes expire without saving the mute data
+	now := clock.Now()
+	var samples []metrics.Sample
+	for guildID, expiries := range muteSnapshot.voteExpiries {
+		active := 0
+		for _, expiry := range expiries {
+			if now.Before(expiry) {
+				active++
+			}
+		}
+		samples = append(samples, metrics.Sample{Labels: []string{guildID}, Value: float64(active)})
+	}
+	return samples
+}
+
+func mutedUserSamples() []metrics.Sample {
+	muteSnapshot.Lock()
+	defer muteSnapshot.Unlock()
+
+	var samples []metrics.Sample
+	for guildID, muted := range muteSnapshot.mutedUsers {
+		samples = append(samples, metrics.Sample{Labels: []string{guildID}, Value: float64(muted)})
+	}
+	return samples
+}
+
+// countEvent updates the counters of the moderation event
+func countEvent(event audit.Event) {
+	switch event.Type {
+	case "VOTE":
+		votesCast.Inc(event.GuildID)
+	case "MUTE", "FORCE_MUTE":
+		// A moderator already muted the user, the bot only keeps the record
+		if event.ManualMute {
+			mutesApplied.Inc(event.GuildID, "MANUAL_MUTE")
+			return
+		}
+		mutesApplied.Inc(event.GuildID, event.Type)
+	case "UNMUTE", "FORCE_UNMUTE", "MANUAL_OVERRIDE":
+		mutesLifted.Inc(event.GuildID, event.Type)
+	}
+}
+
+func observeCommand(command string, start time.Time) {
+	commandDuration.Observe(time.Since(start).Seconds(), command)
+}
+
+// startHTTPServer serves the metrics and the health checks on the address, like ":8080"
+func startHTTPServer(addr string) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metricsRegistry.Handler())
+	mux.HandleFunc("/healthz", handleHealthz)
+	mux.HandleFunc("/readyz", handleReadyz)
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		slog.Info("HTTP server listening", "addr", addr)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			slog.Error("Error in HTTP server", "addr", addr, "error", err)
+		}
+	}()
+	return server
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/tu-usuario/DiscMuteBot/audit"
+)
+
+// Verbosity levels of the mod-log channel
+const (
+	LOG_MINIMAL = "minimal" // Everything except single votes
+	LOG_NORMAL  = "normal"  // Every event
+	LOG_VERBOSE = "verbose" // Every event with the list of voters
+)
+
+// Events that are too frequent for the minimal level
+var frequentEvents = map[string]bool{
+	"VOTE":      true,
+	"JURY_VOTE": true,
+}
+
+var eventColors = map[string]int{
+	"VOTE":            0x95a5a6,
+	"MUTE":            0xe74c3c,
+	"FORCE_MUTE":      0xc0392b,
+	"UNMUTE":          0x2ecc71,
+	"FORCE_UNMUTE":    0x27ae60,
+	"CLEAN":           0x3498db,
+	"CONFIG":          0x9b59b6,
+	"APPEAL":          0xf1c40f,
+	"APPEAL_ACCEPTED": 0x2ecc71,
+	"APPEAL_REJECTED": 0xe74c3c,
+	"VOTEBAN":         0xe67e22,
+	"VOTEUNBAN":       0x2ecc71,
+	"DEAD_LETTER":     0x992d22,
+	"MANUAL_OVERRIDE": 0x1abc9c,
+}
+
+// postModLog sends the event to the mod-log channel of the guild, if configured
+func postModLog(s Discord, event audit.Event) {
+	settings := guildSettings(event.GuildID)
+	if settings.LogChannel == "" {
+		return
+	}
+	if settings.LogVerbosity == LOG_MINIMAL && frequentEvents[event.Type] {
+		return
+	}
+
+	_, err := s.ChannelMessageSendEmbed(settings.LogChannel, modLogEmbed(event, settings.LogVerbosity == LOG_VERBOSE))
+	if err != nil {
+		slog.Error("Error sending event to mod-log channel", "guild_id", event.GuildID, "channel_id", settings.LogChannel,
+			"type", event.Type, "error", err)
+	}
+}
+
+func modLogEmbed(event audit.Event, verbose bool) *discordgo.MessageEmbed {
+	color, exists := eventColors[event.Type]
+	if !exists {
+		color = 0x7f8c8d
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:     event.Type,
+		Color:     color,
+		Timestamp: event.Time.Format("2006-01-02T15:04:05Z07:00"),
+	}
+
+	if event.InitiatorID != "" {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name: "Initiator", Value: fmt.Sprintf("<@%s>\n`%s`", event.InitiatorID, event.InitiatorID), Inline: true,
+		})
+	} else {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name: "Initiator", Value: event.Initiator, Inline: true,
+		})
+	}
+
+	if event.TargetID != "" {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name: "Target", Value: fmt.Sprintf("<@%s>\n`%s`", event.TargetID, event.TargetID), Inline: true,
+		})
+	}
+	if event.Key != "" {
+		embed.Description = fmt.Sprintf("`%s`: %s -> %s", event.Key, event.OldValue, event.NewValue)
+	}
+
+	if event.Votes > 0 {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name: "Votes", Value: fmt.Sprintf("%d", event.Votes), Inline: true,
+		})
+	}
+	if !event.ExpiresAt.IsZero() {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name: "Expires", Value: fmt.Sprintf("<t:%d:R>", event.ExpiresAt.Unix()), Inline: true,
+		})
+	}
+	if event.Reason != "" {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name: "Reason", Value: truncate(event.Reason, 1024),
+		})
+	}
+
+	if verbose && len(event.Voters) > 0 {
+		var voters []string
+		for _, voterID := range event.Voters {
+			voters = append(voters, fmt.Sprintf("<@%s> `%s`", voterID, voterID))
+		}
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name: "Voters", Value: truncate(strings.Join(voters, "\n"), 1024),
+		})
+	}
+
+	if event.MessageID != "" && event.ChannelID != "" {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  "Message",
+			Value: fmt.Sprintf("[Jump to message](https://discord.com/channels/%s/%s/%s)", event.GuildID, event.ChannelID, event.MessageID),
+		})
+	}
+	return embed
+}
+
+// truncate cuts the text to length characters, ending it with "..." when there is room for it
+func truncate(text string, length int) string {
+	cut := cutRunes(text, length)
+	if cut == text || length < 3 {
+		return cut
+	}
+	return cutRunes(text, length-3) + "..."
+}
@@ -0,0 +1,671 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestVoteBelowThreshold(t *testing.T) {
+	discord := setup(t)
+	setVotesNeeded(3)
+	voter := discord.addMember("alice")
+	target := discord.addMember("bob")
+	discord.joinVoice(target.ID, false)
+
+	messageCreate(discord, message(voter, "!mute @bob", target))
+
+	muteInfo := muteData.MutedUsers[target.ID]
+	if _, voted := muteInfo.MutedBy[voter.ID]; !voted {
+		t.Fatalf("vote of %s not registered: %+v", voter.ID, muteInfo)
+	}
+	if muteInfo.IsGloballyMuted || discord.serverMuted(target.ID) {
+		t.Error("muted with 1 of 3 votes")
+	}
+	if msg := discord.lastMessage(testChannel); !strings.Contains(msg, "Current votes: 1/3") {
+		t.Errorf("unexpected answer: %q", msg)
+	}
+	if types := eventTypes(); !reflect.DeepEqual(types, []string{"VOTE"}) {
+		t.Errorf("events = %v, want [VOTE]", types)
+	}
+}
+
+func TestVotesReachThreshold(t *testing.T) {
+	discord := setup(t)
+	setVotesNeeded(2)
+	target := discord.addMember("bob")
+	discord.joinVoice(target.ID, false)
+
+	for _, voterID := range []string{"alice", "carol"} {
+		messageCreate(discord, message(discord.addMember(voterID), "!mute @bob", target))
+	}
+
+	muteInfo := muteData.MutedUsers[target.ID]
+	if !muteInfo.IsGloballyMuted {
+		t.Fatal("not muted after reaching the threshold")
+	}
+	if !discord.serverMuted(target.ID) {
+		t.Error("no server mute in Discord")
+	}
+	if muteInfo.ManualMute {
+		t.Error("bot mute marked as manual")
+	}
+	if remaining := timeUntil(muteInfo.MuteExpiry); remaining != MUTE_DURATION {
+		t.Errorf("mute expires in %s, want %s", remaining, MUTE_DURATION)
+	}
+	if msg := discord.lastMessage(testChannel); !strings.Contains(msg, "has been muted") {
+		t.Errorf("unexpected announcement: %q", msg)
+	}
+	if types := eventTypes(); !reflect.DeepEqual(types, []string{"VOTE", "VOTE", "MUTE"}) {
+		t.Errorf("events = %v, want [VOTE VOTE MUTE]", types)
+	}
+}
+
+func TestRejectedVotes(t *testing.T) {
+	tests := []struct {
+		name    string
+		prepare func(discord *fakeDiscord, voter, target *discordgo.User)
+		self    bool
+		answer  string
+	}{
+		{
+			name:   "self",
+			self:   true,
+			answer: "can't vote to mute yourself",
+		},
+		{
+			name: "bot",
+			prepare: func(discord *fakeDiscord, voter, target *discordgo.User) {
+				target.Bot = true
+			},
+			answer: "can't vote to mute a bot",
+		},
+		{
+			name: "twice",
+			prepare: func(discord *fakeDiscord, voter, target *discordgo.User) {
+				messageCreate(discord, message(voter, "!mute @bob", target))
+			},
+			answer: "already voted",
+		},
+		{
+			name: "banned voter",
+			prepare: func(discord *fakeDiscord, voter, target *discordgo.User) {
+				muteData.VoteBans[testGuild] = map[string]VoteBan{voter.ID: {UserID: voter.ID, CreatedAt: clock.Now()}}
+			},
+			answer: "banned from voting",
+		},
+		{
+			name: "immune role",
+			prepare: func(discord *fakeDiscord, voter, target *discordgo.User) {
+				member, _ := discord.StateMember(testGuild, target.ID)
+				member.Roles = []string{"vip"}
+				settings := guildSettings(testGuild)
+				settings.ImmuneRoles = []string{"vip"}
+				guildConfigs[testGuild] = settings
+			},
+			answer: "immune role",
+		},
+		{
+			name: "already muted",
+			prepare: func(discord *fakeDiscord, voter, target *discordgo.User) {
+				muteData.MutedUsers[target.ID] = MuteInfo{
+					GuildID:         testGuild,
+					MutedBy:         make(map[string]time.Time),
+					IsGloballyMuted: true,
+					MuteExpiry:      clock.Now().Add(time.Minute),
+				}
+			},
+			answer: "already muted",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			discord := setup(t)
+			setVotesNeeded(3)
+			voter := discord.addMember("alice")
+			target := discord.addMember("bob")
+			discord.joinVoice(target.ID, false)
+			if test.self {
+				target = voter
+			}
+			if test.prepare != nil {
+				test.prepare(discord, voter, target)
+			}
+			votes := len(muteData.MutedUsers[target.ID].MutedBy)
+
+			messageCreate(discord, message(voter, "!mute @"+target.ID, target))
+
+			if msg := discord.lastMessage(testChannel); !strings.Contains(msg, test.answer) {
+				t.Errorf("answer = %q, want %q", msg, test.answer)
+			}
+			if got := len(muteData.MutedUsers[target.ID].MutedBy); got != votes {
+				t.Errorf("votes = %d, want %d", got, votes)
+			}
+		})
+	}
+}
+
+func TestMuteOutsideVoiceFails(t *testing.T) {
+	discord := setup(t)
+	setVotesNeeded(1)
+	target := discord.addMember("bob")
+
+	messageCreate(discord, message(discord.addMember("alice"), "!mute @bob", target))
+
+	if muteData.MutedUsers[target.ID].IsGloballyMuted {
+		t.Error("muted although Discord rejected the mute")
+	}
+	if msg := discord.lastMessage(testChannel); !strings.Contains(msg, "Error muting bob") {
+		t.Errorf("unexpected answer: %q", msg)
+	}
+	if len(muteData.Actions) != 0 {
+		t.Errorf("rejected mute queued: %+v", muteData.Actions)
+	}
+}
+
+// mute mutes the target through the votes of one voter
+func mute(t *testing.T, discord *fakeDiscord, target *discordgo.User) {
+	t.Helper()
+	setVotesNeeded(1)
+	messageCreate(discord, message(discord.addMember("voter-"+target.ID), "!mute @"+target.ID, target))
+	if !muteData.MutedUsers[target.ID].IsGloballyMuted {
+		t.Fatalf("%s not muted: %q", target.ID, discord.lastMessage(testChannel))
+	}
+}
+
+func TestUnmuteUser(t *testing.T) {
+	discord := setup(t)
+	target := discord.addMember("bob")
+	discord.joinVoice(target.ID, false)
+	mute(t, discord, target)
+
+	unmuteUser(discord, testGuild, target.ID)
+
+	if muteData.MutedUsers[target.ID].IsGloballyMuted {
+		t.Error("still muted in the mute data")
+	}
+	if discord.serverMuted(target.ID) {
+		t.Error("still muted in Discord")
+	}
+	if types := eventTypes(); types[len(types)-1] != "UNMUTE" {
+		t.Errorf("events = %v, want UNMUTE last", types)
+	}
+}
+
+func TestUnmuteKeepsModeratorMute(t *testing.T) {
+	discord := setup(t)
+	target := discord.addMember("bob")
+	// A moderator muted bob before the vote
+	discord.joinVoice(target.ID, true)
+	mute(t, discord, target)
+
+	if !muteData.MutedUsers[target.ID].ManualMute {
+		t.Fatal("moderator mute not detected")
+	}
+	muteCalls := discord.muteCalls
+
+	unmuteUser(discord, testGuild, target.ID)
+
+	if muteData.MutedUsers[target.ID].IsGloballyMuted {
+		t.Error("bot mute not ended")
+	}
+	if !discord.serverMuted(target.ID) {
+		t.Error("moderator mute lifted")
+	}
+	if discord.muteCalls != muteCalls {
+		t.Errorf("%d mute changes requested, want none", discord.muteCalls-muteCalls)
+	}
+}
+
+func TestManualMuteMetric(t *testing.T) {
+	discord := setup(t)
+	target := discord.addMember("bob")
+	discord.joinVoice(target.ID, true)
+	mutes := `discmutebot_mutes_total{guild="` + testGuild + `",action="MUTE"}`
+	manual := `discmutebot_mutes_total{guild="` + testGuild + `",action="MANUAL_MUTE"}`
+	before := metricValue(t, mutes)
+
+	mute(t, discord, target)
+
+	if value := metricValue(t, mutes); value != before {
+		t.Errorf("%s = %v, want %v: no server mute was applied", mutes, value, before)
+	}
+	if value := metricValue(t, manual); value == 0 {
+		t.Errorf("%s not counted", manual)
+	}
+}
+
+func TestCleanRequiresPermission(t *testing.T) {
+	discord := setup(t)
+	target := discord.addMember("bob")
+	discord.joinVoice(target.ID, false)
+	mute(t, discord, target)
+	member := discord.addMember("mallory")
+
+	messageCreate(discord, message(member, "!clean @bob", target))
+
+	if msg := discord.lastMessage(testChannel); !strings.Contains(msg, "don't have permission") {
+		t.Errorf("unexpected answer: %q", msg)
+	}
+	if !discord.serverMuted(target.ID) {
+		t.Error("unmuted without permission")
+	}
+
+	moderator := discord.addMember("mod")
+	discord.permissions[moderator.ID] = discordgo.PermissionVoiceMuteMembers
+	messageCreate(discord, message(moderator, "!clean @bob", target))
+
+	if _, exists := muteData.MutedUsers[target.ID]; exists {
+		t.Error("votes not removed")
+	}
+	if discord.serverMuted(target.ID) {
+		t.Error("still muted in Discord")
+	}
+}
+
+func TestVoiceStateUpdateKeepsMute(t *testing.T) {
+	discord := setup(t)
+	target := discord.addMember("bob")
+	discord.joinVoice(target.ID, false)
+	muteData.MutedUsers[target.ID] = MuteInfo{
+		GuildID:         testGuild,
+		MutedBy:         make(map[string]time.Time),
+		IsGloballyMuted: true,
+		MuteExpiry:      clock.Now().Add(time.Minute),
+	}
+
+	// bob was muted while he wasn't in a voice channel and joins one
+	voiceStateUpdate(discord, &discordgo.VoiceStateUpdate{VoiceState: &discordgo.VoiceState{
+		GuildID: testGuild, ChannelID: testVoice, UserID: target.ID,
+	}})
+
+	if !discord.serverMuted(target.ID) {
+		t.Error("mute not applied when joining")
+	}
+}
+
+func TestVoiceStateUpdateLiftsExpiredMute(t *testing.T) {
+	discord := setup(t)
+	target := discord.addMember("bob")
+	discord.joinVoice(target.ID, true)
+	muteData.MutedUsers[target.ID] = MuteInfo{
+		GuildID:         testGuild,
+		MutedBy:         make(map[string]time.Time),
+		IsGloballyMuted: true,
+		MuteExpiry:      clock.Now().Add(-time.Minute),
+	}
+
+	voiceStateUpdate(discord, &discordgo.VoiceStateUpdate{VoiceState: &discordgo.VoiceState{
+		GuildID: testGuild, ChannelID: testVoice, UserID: target.ID, Mute: true,
+	}})
+
+	if discord.serverMuted(target.ID) || muteData.MutedUsers[target.ID].IsGloballyMuted {
+		t.Error("expired mute not lifted")
+	}
+}
+
+func TestManualUnmuteOverride(t *testing.T) {
+	discord := setup(t)
+	target := discord.addMember("bob")
+	discord.joinVoice(target.ID, false)
+	mute(t, discord, target)
+	// The bot changes of the mute are old
+	botMuteChanges.changes = make(map[string]botMuteChange)
+
+	// A moderator unmutes bob by hand
+	discord.voiceState(target.ID).Mute = false
+	voiceStateUpdate(discord, &discordgo.VoiceStateUpdate{
+		VoiceState:   &discordgo.VoiceState{GuildID: testGuild, ChannelID: testVoice, UserID: target.ID},
+		BeforeUpdate: &discordgo.VoiceState{GuildID: testGuild, ChannelID: testVoice, UserID: target.ID, Mute: true},
+	})
+
+	if muteData.MutedUsers[target.ID].IsGloballyMuted {
+		t.Error("bot mute not ended")
+	}
+	if discord.serverMuted(target.ID) {
+		t.Error("muted again by the bot")
+	}
+	if types := eventTypes(); types[len(types)-1] != "MANUAL_OVERRIDE" {
+		t.Errorf("events = %v, want MANUAL_OVERRIDE last", types)
+	}
+
+	recorder := httptest.NewRecorder()
+	metricsRegistry.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if counter := `discmutebot_unmutes_total{guild="` + testGuild + `",action="MANUAL_OVERRIDE"}`; !strings.Contains(recorder.Body.String(), counter) {
+		t.Errorf("metrics don't count the override as an unmute:\n%s", recorder.Body.String())
+	}
+}
+
+func TestVoteAfterManualUnmuteStartsOver(t *testing.T) {
+	discord := setup(t)
+	setVotesNeeded(2)
+	target := discord.addMember("bob")
+	discord.joinVoice(target.ID, false)
+	messageCreate(discord, message(discord.addMember("alice"), "!mute @bob", target))
+	messageCreate(discord, message(discord.addMember("carol"), "!mute @bob", target))
+	botMuteChanges.changes = make(map[string]botMuteChange)
+
+	discord.voiceState(target.ID).Mute = false
+	voiceStateUpdate(discord, &discordgo.VoiceStateUpdate{
+		VoiceState:   &discordgo.VoiceState{GuildID: testGuild, ChannelID: testVoice, UserID: target.ID},
+		BeforeUpdate: &discordgo.VoiceState{GuildID: testGuild, ChannelID: testVoice, UserID: target.ID, Mute: true},
+	})
+
+	// The votes before the override don't count anymore
+	messageCreate(discord, message(discord.addMember("dave"), "!mute @bob", target))
+	if muteData.MutedUsers[target.ID].IsGloballyMuted || discord.serverMuted(target.ID) {
+		t.Fatal("muted again by a single vote")
+	}
+	if msg := discord.lastMessage(testChannel); !strings.Contains(msg, "Current votes: 1/2") {
+		t.Errorf("answer = %q, want 1/2 votes", msg)
+	}
+}
+
+func TestFailedUnmuteIsRetried(t *testing.T) {
+	discord := setup(t)
+	target := discord.addMember("bob")
+	discord.joinVoice(target.ID, false)
+	mute(t, discord, target)
+
+	failures := 2
+	discord.muteHook = func(guildID, userID string, mute bool) error {
+		if failures > 0 {
+			failures--
+			return restError(http.StatusBadGateway, 0)
+		}
+		return nil
+	}
+
+	unmuteUser(discord, testGuild, target.ID)
+
+	// The mute ends for the bot, Discord gets the unmute from the queue
+	if muteData.MutedUsers[target.ID].IsGloballyMuted {
+		t.Error("still muted in the mute data")
+	}
+	action, queued := muteData.Actions[actionKey(testGuild, target.ID)]
+	if !queued || action.Mute {
+		t.Fatalf("unmute not queued: %+v", muteData.Actions)
+	}
+
+	now := clock.Now()
+	processActions(discord, now)
+	if action := muteData.Actions[actionKey(testGuild, target.ID)]; action.Attempts != 1 {
+		t.Fatalf("action retried before its time: %+v", action)
+	}
+
+	now = now.Add(ACTION_BASE_BACKOFF)
+	processActions(discord, now)
+	action = muteData.Actions[actionKey(testGuild, target.ID)]
+	if action.Attempts != 2 || action.NextAttempt.Sub(now) != 2*ACTION_BASE_BACKOFF {
+		t.Fatalf("backoff not doubled: %+v", action)
+	}
+
+	processActions(discord, action.NextAttempt)
+	if len(muteData.Actions) != 0 {
+		t.Errorf("unmute still queued: %+v", muteData.Actions)
+	}
+	if discord.serverMuted(target.ID) {
+		t.Error("still muted in Discord")
+	}
+}
+
+func TestFailedMuteIsAnnouncedAsPending(t *testing.T) {
+	discord := setup(t)
+	target := discord.addMember("bob")
+	discord.joinVoice(target.ID, false)
+	discord.muteHook = func(guildID, userID string, mute bool) error {
+		return restError(http.StatusServiceUnavailable, 0)
+	}
+
+	mute(t, discord, target)
+
+	if msg := discord.lastMessage(testChannel); !strings.Contains(msg, "Discord didn't accept the change yet") {
+		t.Errorf("announcement = %q, want the mute pending", msg)
+	}
+	if _, queued := muteData.Actions[actionKey(testGuild, target.ID)]; !queued {
+		t.Errorf("mute not queued: %+v", muteData.Actions)
+	}
+}
+
+func TestRejectedUnmuteIsDeadLettered(t *testing.T) {
+	discord := setup(t)
+	target := discord.addMember("bob")
+	discord.joinVoice(target.ID, false)
+	mute(t, discord, target)
+
+	// bob left the guild
+	discord.muteHook = func(guildID, userID string, mute bool) error {
+		return restError(http.StatusNotFound, discordgo.ErrCodeUnknownMember)
+	}
+	unmuteUser(discord, testGuild, target.ID)
+
+	if muteData.MutedUsers[target.ID].IsGloballyMuted {
+		t.Error("bot mute not ended")
+	}
+	if len(muteData.Actions) != 0 {
+		t.Errorf("rejected unmute queued: %+v", muteData.Actions)
+	}
+	if len(muteData.DeadLetters) != 1 || muteData.DeadLetters[0].Mute {
+		t.Fatalf("dead letters = %+v", muteData.DeadLetters)
+	}
+	if types := eventTypes(); !reflect.DeepEqual(types[len(types)-2:], []string{"DEAD_LETTER", "UNMUTE"}) {
+		t.Errorf("events = %v, want DEAD_LETTER and UNMUTE last", types)
+	}
+}
+
+func TestQueuedActionsHaveUniqueIDs(t *testing.T) {
+	discord := setup(t)
+	discord.muteHook = func(guildID, userID string, mute bool) error {
+		return restError(http.StatusBadGateway, 0)
+	}
+
+	// Both fail at the same instant of the clock
+	for _, username := range []string{"bob", "carol"} {
+		target := discord.addMember(username)
+		discord.joinVoice(target.ID, true)
+		guildMemberMute(discord, testGuild, target.ID, false)
+	}
+
+	bob, carol := muteData.Actions[actionKey(testGuild, "bob")], muteData.Actions[actionKey(testGuild, "carol")]
+	if bob.ID == "" || bob.ID == carol.ID {
+		t.Errorf("action IDs = %q and %q, want different IDs", bob.ID, carol.ID)
+	}
+}
+
+func TestRejectedMuteIsDeadLettered(t *testing.T) {
+	discord := setup(t)
+	target := discord.addMember("bob")
+	discord.joinVoice(target.ID, false)
+	muteData.MutedUsers[target.ID] = MuteInfo{
+		GuildID:         testGuild,
+		MutedBy:         make(map[string]time.Time),
+		IsGloballyMuted: true,
+		MuteExpiry:      clock.Now().Add(time.Hour),
+	}
+	queueAction(PendingAction{ID: "1", GuildID: testGuild, UserID: target.ID, Mute: true, Attempts: 1})
+	discord.muteHook = func(guildID, userID string, mute bool) error {
+		return restError(http.StatusForbidden, discordgo.ErrCodeMissingPermissions)
+	}
+
+	processActions(discord, clock.Now())
+
+	if len(muteData.Actions) != 0 {
+		t.Errorf("rejected mute still queued: %+v", muteData.Actions)
+	}
+	if len(muteData.DeadLetters) != 1 || muteData.DeadLetters[0].Attempts != 2 {
+		t.Fatalf("dead letters = %+v", muteData.DeadLetters)
+	}
+	if types := eventTypes(); !reflect.DeepEqual(types, []string{"DEAD_LETTER"}) {
+		t.Errorf("events = %v, want [DEAD_LETTER]", types)
+	}
+}
+
+func TestTimeoutSanction(t *testing.T) {
+	discord := setup(t)
+	target := discord.addMember("bob")
+	discord.joinVoice(target.ID, false)
+	settings := defaultSettings(testGuild)
+	settings.VotesNeeded = 1
+	settings.SanctionMode = SANCTION_TIMEOUT
+	guildConfigs[testGuild] = settings
+
+	messageCreate(discord, message(discord.addMember("alice"), "!mute @bob", target))
+	if !discord.serverMuted(target.ID) || !discord.timedOut(target.ID) {
+		t.Fatalf("bob not muted and timed out: %q", discord.lastMessage(testChannel))
+	}
+	if msg := discord.lastMessage(testChannel); !strings.Contains(msg, "Timed out until") {
+		t.Errorf("announcement = %q, want the timeout", msg)
+	}
+
+	messageCreate(discord, message(discord.addMember(testOwner), "!forceunmute @bob", target))
+	if discord.serverMuted(target.ID) || discord.timedOut(target.ID) {
+		t.Error("mute or timeout not lifted")
+	}
+	if muteData.MutedUsers[target.ID].TimedOut {
+		t.Error("timeout still recorded")
+	}
+}
+
+func TestLongAppealKeepsWholeCharacters(t *testing.T) {
+	discord := setup(t)
+	target := discord.addMember("bob")
+	discord.joinVoice(target.ID, false)
+	mute(t, discord, target)
+	settings := guildSettings(testGuild)
+	settings.AppealChannel = testChannel
+	guildConfigs[testGuild] = settings
+
+	createAppeal(discord, testGuild, target, strings.Repeat("ñ", APPEAL_MAX_LENGTH+1))
+
+	if len(muteData.Appeals) != 1 {
+		t.Fatalf("appeals = %+v", muteData.Appeals)
+	}
+	if text := muteData.Appeals["1"].Text; !utf8.ValidString(text) || utf8.RuneCountInString(text) != APPEAL_MAX_LENGTH {
+		t.Errorf("appeal text has %d characters, valid UTF-8: %v", utf8.RuneCountInString(text), utf8.ValidString(text))
+	}
+}
+
+func TestAppealIDsAreUnique(t *testing.T) {
+	discord := setup(t)
+	settings := guildSettings(testGuild)
+	settings.AppealChannel = testChannel
+	guildConfigs[testGuild] = settings
+	// Saved before the counter, the first appeal was removed
+	muteData.Appeals["2"] = Appeal{ID: "2", GuildID: testGuild, UserID: "old", Status: APPEAL_REJECTED}
+
+	for _, name := range []string{"bob", "carol"} {
+		target := discord.addMember(name)
+		discord.joinVoice(target.ID, false)
+		mute(t, discord, target)
+		createAppeal(discord, testGuild, target, "sorry")
+	}
+
+	if len(muteData.Appeals) != 3 {
+		t.Fatalf("appeals = %+v, want 3", muteData.Appeals)
+	}
+	if appeal := muteData.Appeals["2"]; appeal.UserID != "old" {
+		t.Errorf("appeal #2 = %+v, replaced by a new appeal", appeal)
+	}
+}
+
+func TestJuryIDsAreUnique(t *testing.T) {
+	discord := setup(t)
+	discord.joinVoice(discord.addMember("alice").ID, false)
+	// Saved before the counter, the first jury was removed
+	muteData.Juries["2"] = Jury{ID: "2", GuildID: testGuild, TargetID: "old", Result: JURY_REJECTED}
+
+	for _, name := range []string{"bob", "carol"} {
+		target := discord.addMember(name)
+		discord.joinVoice(target.ID, false)
+		if !startJury(discord, testGuild, testChannel, target, MuteInfo{}) {
+			t.Fatalf("no jury for %s", name)
+		}
+	}
+
+	if len(muteData.Juries) != 3 {
+		t.Fatalf("juries = %+v, want 3", muteData.Juries)
+	}
+	if jury := muteData.Juries["2"]; jury.TargetID != "old" {
+		t.Errorf("jury #2 = %+v, replaced by a new jury", jury)
+	}
+}
+
+// pressButton sends the click of the user on a message button of the text channel
+func pressButton(discord *fakeDiscord, user *discordgo.User, customID string) {
+	interactionCreate(discord, &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+		Type:      discordgo.InteractionMessageComponent,
+		GuildID:   testGuild,
+		ChannelID: testChannel,
+		Member:    &discordgo.Member{GuildID: testGuild, User: user},
+		Data:      discordgo.MessageComponentInteractionData{CustomID: customID},
+	}})
+}
+
+func TestAppealResolveChecksGuild(t *testing.T) {
+	discord := setup(t)
+	moderator := discord.addMember(testOwner)
+	target := discord.addMember("bob")
+	discord.joinVoice(target.ID, false)
+	mute(t, discord, target)
+	settings := guildSettings(testGuild)
+	settings.AppealChannel = testChannel
+	guildConfigs[testGuild] = settings
+	createAppeal(discord, testGuild, target, "sorry")
+	muteData.Appeals["other"] = Appeal{ID: "other", GuildID: "other guild", UserID: "carol", Status: APPEAL_PENDING}
+
+	pressButton(discord, moderator, "appeal_accept:other")
+
+	if msg := discord.lastMessage(testChannel); !strings.Contains(msg, "not found") {
+		t.Errorf("answer = %q, want not found", msg)
+	}
+	if status := muteData.Appeals["other"].Status; status != APPEAL_PENDING {
+		t.Errorf("appeal of another server %s", status)
+	}
+
+	pressButton(discord, moderator, "appeal_accept:1")
+
+	if status := muteData.Appeals["1"].Status; status != APPEAL_ACCEPTED {
+		t.Errorf("appeal status = %s, want %s", status, APPEAL_ACCEPTED)
+	}
+	if muteData.MutedUsers[target.ID].IsGloballyMuted {
+		t.Error("still muted after the accepted appeal")
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	for _, test := range []struct {
+		text   string
+		length int
+		want   string
+	}{
+		{"short", 10, "short"},
+		{"ñandú ñandú", 8, "ñandú..."},
+		{"ñandú", 2, "ña"},
+		{"ñandú", 0, ""},
+	} {
+		if got := truncate(test.text, test.length); got != test.want {
+			t.Errorf("truncate(%q, %d) = %q, want %q", test.text, test.length, got, test.want)
+		}
+	}
+}
+
+func TestStatsUseTheClock(t *testing.T) {
+	discord := setup(t)
+	target := discord.addMember("bob")
+	discord.joinVoice(target.ID, false)
+	mute(t, discord, target)
+	testClock.Advance(time.Hour)
+
+	messageCreate(discord, message(discord.addMember("alice"), "!stats 24h"))
+
+	if msg := discord.lastMessage(testChannel); !strings.Contains(msg, "Mutes: 1") {
+		t.Errorf("stats = %q, want the mute of the last day", msg)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/tu-usuario/DiscMuteBot/audit"
+)
+
+// Time after a mute change of the bot during which the voice states that match it are
+// considered its own, and the age of the Discord audit log entries that explain a change
+const MUTE_CHANGE_WINDOW = time.Minute
+
+// Mute changes requested by the bot, by guild and user, to tell them apart from the moderators'
+var botMuteChanges = struct {
+	sync.Mutex
+	changes map[string]botMuteChange
+}{changes: make(map[string]botMuteChange)}
+
+type botMuteChange struct {
+	Mute bool
+	At   time.Time
+}
+
+// expectMuteChange registers a mute change the bot is about to request
+func expectMuteChange(guildID, userID string, mute bool) {
+	botMuteChanges.Lock()
+	defer botMuteChanges.Unlock()
+	botMuteChanges.changes[actionKey(guildID, userID)] = botMuteChange{Mute: mute, At: clock.Now()}
+}
+
+// madeByBot tells whether the bot recently requested the mute status of the member
+func madeByBot(guildID, userID string, mute bool) bool {
+	botMuteChanges.Lock()
+	defer botMuteChanges.Unlock()
+	change, exists := botMuteChanges.changes[actionKey(guildID, userID)]
+	return exists && change.Mute == mute && timeSince(change.At) < MUTE_CHANGE_WINDOW
+}
+
+// mutedByOthers tells whether the member has a server mute the bot didn't apply
+func mutedByOthers(s Discord, guildID, userID string) bool {
+	vs, err := s.StateVoiceState(guildID, userID)
+	if err != nil || !vs.Mute {
+		return false
+	}
+
+	// The voice state may still show a mute change of the bot
+	if _, queued := muteData.Actions[actionKey(guildID, userID)]; queued {
+		return false
+	}
+	botMuteChanges.Lock()
+	defer botMuteChanges.Unlock()
+	change, exists := botMuteChanges.changes[actionKey(guildID, userID)]
+	return !exists || timeSince(change.At) >= MUTE_CHANGE_WINDOW
+}
+
+// detectManualUnmute checks whether someone else lifted the server mute of a member the bot
+// muted, while they were in a voice channel. The bot then ends its mute instead of muting them
+// again. Returns true if the update was a manual override.
+func detectManualUnmute(s Discord, v *discordgo.VoiceStateUpdate, muteInfo MuteInfo) bool {
+	if v.BeforeUpdate == nil || v.BeforeUpdate.ChannelID == "" || !v.BeforeUpdate.Mute || v.Mute || v.ChannelID == "" {
+		return false
+	}
+	if madeByBot(v.GuildID, v.UserID, false) {
+		return false
+	}
+
+	// The audit log of the guild names the moderator, when the bot can read it
+	moderatorID, found := muteChangeAuthor(s, v.GuildID, v.UserID, false)
+	if found && s.BotUser() != nil && moderatorID == s.BotUser().ID {
+		return false
+	}
+
+	// Like !forceunmute, the votes are discarded so the next vote doesn't mute again
+	endTimeout(s, v.GuildID, v.UserID, &muteInfo)
+	muteInfo.IsGloballyMuted = false
+	muteInfo.Reason = ""
+	muteInfo.MutedBy = make(map[string]time.Time)
+	muteData.MutedUsers[v.UserID] = muteInfo
+	delete(muteData.Actions, actionKey(v.GuildID, v.UserID))
+	saveMuteData()
+
+	event := audit.Event{
+		Type:     "MANUAL_OVERRIDE",
+		GuildID:  v.GuildID,
+		TargetID: v.UserID,
+		Target:   v.UserID,
+		Reason:   "Server mute lifted outside of the bot",
+	}
+	if v.Member != nil && v.Member.User != nil {
+		event.Target = v.Member.User.Username
+	}
+	event.Initiator = "Unknown moderator"
+	if found {
+		event.InitiatorID = moderatorID
+		event.Initiator = moderatorID
+		if user, err := s.User(moderatorID); err == nil {
+			event.Initiator = user.Username
+		}
+	}
+	recordEvent(event)
+
+	slog.Info("Mute lifted by a moderator, ending the bot mute", "guild_id", v.GuildID, "user_id", v.UserID,
+		"moderator_id", moderatorID)
+	return true
+}
+
+// muteChangeAuthor looks in the audit log of the guild for who changed the server mute of the member
+// in the last minute. Needs the View Audit Log permission.
+func muteChangeAuthor(s Discord, guildID, userID string, mute bool) (string, bool) {
+	auditLog, err := s.GuildAuditLog(guildID, "", "", int(discordgo.AuditLogActionMemberUpdate), 10)
+	if err != nil {
+		slog.Debug("Can't read the audit log of the guild", "guild_id", guildID, "error", err)
+		return "", false
+	}
+
+	for _, entry := range auditLog.AuditLogEntries {
+		if entry.TargetID != userID {
+			continue
+		}
+		if created, err := discordgo.SnowflakeTimestamp(entry.ID); err != nil || timeSince(created) > MUTE_CHANGE_WINDOW {
+			continue
+		}
+		for _, change := range entry.Changes {
+			if change.Key != nil && *change.Key == discordgo.AuditLogChangeKeyMute && change.NewValue == mute {
+				return entry.UserID, true
+			}
+		}
+	}
+	return "", false
+}
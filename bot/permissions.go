@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Privileged commands and the permission they need unless the guild configures another one
+var commandPermissions = map[string]int64{
+	"clean":       discordgo.PermissionVoiceMuteMembers,
+	"appeals":     discordgo.PermissionVoiceMuteMembers,
+	"config":      discordgo.PermissionManageServer,
+	"forcemute":   discordgo.PermissionVoiceMuteMembers,
+	"forceunmute": discordgo.PermissionVoiceMuteMembers,
+	"voteban":     discordgo.PermissionVoiceMuteMembers,
+	"history":     discordgo.PermissionVoiceMuteMembers,
+	"deadletters": discordgo.PermissionVoiceMuteMembers,
+}
+
+// Permission names accepted by !config set <command>_permission
+var permissionNames = map[string]int64{
+	"administrator":    discordgo.PermissionAdministrator,
+	"manage_server":    discordgo.PermissionManageServer,
+	"manage_roles":     discordgo.PermissionManageRoles,
+	"manage_channels":  discordgo.PermissionManageChannels,
+	"manage_messages":  discordgo.PermissionManageMessages,
+	"kick_members":     discordgo.PermissionKickMembers,
+	"ban_members":      discordgo.PermissionBanMembers,
+	"moderate_members": discordgo.PermissionModerateMembers,
+	"mute_members":     discordgo.PermissionVoiceMuteMembers,
+	"deafen_members":   discordgo.PermissionVoiceDeafenMembers,
+	"move_members":     discordgo.PermissionVoiceMoveMembers,
+}
+
+func init() {
+	// Every privileged command gets its own role and permission options
+	var commands []string
+	for command := range commandPermissions {
+		commands = append(commands, command)
+	}
+	sort.Strings(commands)
+
+	for _, command := range commands {
+		configOptions = append(configOptions,
+			configOption{
+				Key:         command + "_roles",
+				Description: fmt.Sprintf("Roles that can always use !%s (@role @role... or none)", command),
+				set: func(settings *GuildSettings, guild *discordgo.Guild, value string) error {
+					roles, err := parseRoles(guild, value)
+					if err != nil {
+						return err
+					}
+					settings.CommandRoles = copyMap(settings.CommandRoles)
+					settings.CommandRoles[command] = roles
+					return nil
+				},
+				get: func(settings GuildSettings) string { return formatRoles(settings.CommandRoles[command]) },
+				reset: func(settings *GuildSettings, defaults GuildSettings) {
+					settings.CommandRoles = copyMap(settings.CommandRoles)
+					delete(settings.CommandRoles, command)
+				},
+			},
+			configOption{
+				Key:         command + "_permission",
+				Description: fmt.Sprintf("Permission needed to use !%s, like mute_members or manage_server", command),
+				set: func(settings *GuildSettings, guild *discordgo.Guild, value string) error {
+					value = strings.ToLower(value)
+					if _, exists := permissionNames[value]; !exists {
+						return fmt.Errorf("unknown permission %q, use one of: %s", value, strings.Join(sortedPermissionNames(), ", "))
+					}
+					settings.CommandPermissions = copyMap(settings.CommandPermissions)
+					settings.CommandPermissions[command] = value
+					return nil
+				},
+				get: func(settings GuildSettings) string { return requiredPermissionName(settings, command) },
+				reset: func(settings *GuildSettings, defaults GuildSettings) {
+					settings.CommandPermissions = copyMap(settings.CommandPermissions)
+					delete(settings.CommandPermissions, command)
+				},
+			},
+		)
+	}
+}
+
+// authorize reports whether the user can run the privileged command in the channel.
+// The guild owner and administrators can always run it. Other members need one of the
+// roles configured for the command or the permission it requires, computed with the
+// channel overwrites.
+func authorize(s Discord, guildID, channelID, userID, command string) (bool, error) {
+	guild, err := s.StateGuild(guildID)
+	if err != nil {
+		guild, err = s.Guild(guildID)
+		if err != nil {
+			return false, err
+		}
+	}
+	if guild.OwnerID == userID {
+		return true, nil
+	}
+
+	permissions, err := s.UserChannelPermissions(userID, channelID)
+	if err != nil {
+		return false, err
+	}
+	if permissions&discordgo.PermissionAdministrator == discordgo.PermissionAdministrator {
+		return true, nil
+	}
+
+	settings := guildSettings(guildID)
+
+	// Roles configured for the command
+	if roles := settings.CommandRoles[command]; len(roles) > 0 {
+		member, err := s.StateMember(guildID, userID)
+		if err != nil {
+			member, err = s.GuildMember(guildID, userID)
+			if err != nil {
+				return false, err
+			}
+		}
+		for _, roleID := range member.Roles {
+			for _, allowedRoleID := range roles {
+				if roleID == allowedRoleID {
+					return true, nil
+				}
+			}
+		}
+	}
+
+	required := permissionNames[requiredPermissionName(settings, command)]
+	return permissions&required == required, nil
+}
+
+// requirePermission answers in the channel and returns false if the author can't run the command
+func requirePermission(s Discord, m *discordgo.MessageCreate, command string) bool {
+	allowed, err := authorize(s, m.GuildID, m.ChannelID, m.Author.ID, command)
+	if err != nil {
+		s.ChannelMessageSend(m.ChannelID, "❌ Error verifying permissions")
+		slog.Error("Error verifying permissions", "guild_id", m.GuildID, "user_id", m.Author.ID, "command", command, "error", err)
+		return false
+	}
+
+	if !allowed {
+		s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("❌ You don't have permission to use `!%s`", command))
+		return false
+	}
+	return true
+}
+
+// requireInteractionPermission answers the interaction and returns false if the user can't run the command
+func requireInteractionPermission(s Discord, i *discordgo.InteractionCreate, command string) bool {
+	user := interactionUser(i)
+	allowed, err := authorize(s, i.GuildID, i.ChannelID, user.ID, command)
+	if err != nil {
+		slog.Error("Error verifying permissions", "guild_id", i.GuildID, "user_id", user.ID, "command", command, "error", err)
+		respondEphemeral(s, i, "❌ Error verifying permissions")
+		return false
+	}
+
+	if !allowed {
+		respondEphemeral(s, i, fmt.Sprintf("❌ You don't have permission to use `!%s`", command))
+		return false
+	}
+	return true
+}
+
+// requiredPermissionName returns the permission the guild requires for the command
+func requiredPermissionName(settings GuildSettings, command string) string {
+	if name, exists := settings.CommandPermissions[command]; exists {
+		return name
+	}
+	for name, permission := range permissionNames {
+		if permission == commandPermissions[command] {
+			return name
+		}
+	}
+	return "administrator"
+}
+
+func sortedPermissionNames() []string {
+	var names []string
+	for name := range permissionNames {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func copyMap[V any](m map[string]V) map[string]V {
+	copied := make(map[string]V, len(m)+1)
+	for key, value := range m {
+		copied[key] = value
+	}
+	return copied
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/tu-usuario/DiscMuteBot/audit"
+)
+
+// readAuditEvents returns the events of the audit log in chronological order, from the JSON Lines
+// and the CSV files. The events written to both are taken from the JSON Lines files, which have every field.
+func readAuditEvents(dir string, location *time.Location) ([]audit.Event, error) {
+	return audit.Read(dir, time.Time{}, time.Time{}, location)
+}
+
+// replayEvents rebuilds the votes, mutes and vote bans from the moderation events in chronological order.
+// Appeals and juries aren't rebuilt. Events without the target ID, written with the original CSV
+// columns, are skipped.
+func replayEvents(events []audit.Event, now time.Time) (MuteData, int) {
+	data := MuteData{
+		MutedUsers: make(map[string]MuteInfo),
+		Appeals:    make(map[string]Appeal),
+		Juries:     make(map[string]Jury),
+		VoteBans:   make(map[string]map[string]VoteBan),
+		Actions:    make(map[string]PendingAction),
+	}
+
+	skipped := 0
+	for _, event := range events {
+		if event.TargetID == "" {
+			if event.Type != "CONFIG" {
+				skipped++
+			}
+			continue
+		}
+
+		muteInfo, exists := data.MutedUsers[event.TargetID]
+		if !exists {
+			muteInfo = MuteInfo{GuildID: event.GuildID, MutedBy: make(map[string]time.Time)}
+		}
+
+		switch event.Type {
+		case "VOTE":
+			if event.InitiatorID == "" {
+				skipped++
+				continue
+			}
+			expiry := event.ExpiresAt
+			if expiry.IsZero() {
+				expiry = event.Time.Add(guildSettings(event.GuildID).VoteDuration())
+			}
+			muteInfo.GuildID = event.GuildID
+			muteInfo.MutedBy[event.InitiatorID] = expiry
+		case "MUTE", "FORCE_MUTE":
+			muteInfo.GuildID = event.GuildID
+			muteInfo.IsGloballyMuted = true
+			muteInfo.MuteExpiry = event.ExpiresAt
+			muteInfo.Reason = event.Reason
+			// Mutes of a moderator are left in place when the bot mute ends
+			muteInfo.ManualMute = event.ManualMute
+			muteInfo.TimedOut = event.TimedOut
+		case "UNMUTE":
+			muteInfo.IsGloballyMuted = false
+			muteInfo.ManualMute = false
+			muteInfo.TimedOut = false
+			muteInfo.Reason = ""
+		case "FORCE_UNMUTE", "JURY_REJECTED", "MANUAL_OVERRIDE":
+			muteInfo.IsGloballyMuted = false
+			muteInfo.ManualMute = false
+			muteInfo.TimedOut = false
+			muteInfo.Reason = ""
+			muteInfo.MutedBy = make(map[string]time.Time)
+		case "CLEAN", "APPEAL_ACCEPTED":
+			delete(data.MutedUsers, event.TargetID)
+			continue
+		case "VOTEBAN":
+			bans, exists := data.VoteBans[event.GuildID]
+			if !exists {
+				bans = make(map[string]VoteBan)
+				data.VoteBans[event.GuildID] = bans
+			}
+			bans[event.TargetID] = VoteBan{
+				UserID:    event.TargetID,
+				Username:  event.Target,
+				BannedBy:  event.Initiator,
+				CreatedAt: event.Time,
+				Until:     event.ExpiresAt,
+			}
+			// The votes of the banned user are dropped
+			for _, info := range data.MutedUsers {
+				if info.GuildID == event.GuildID {
+					delete(info.MutedBy, event.TargetID)
+				}
+			}
+			continue
+		case "VOTEUNBAN":
+			delete(data.VoteBans[event.GuildID], event.TargetID)
+			continue
+		default:
+			continue
+		}
+		data.MutedUsers[event.TargetID] = muteInfo
+	}
+
+	// Drop what already expired, expired mutes are kept so the bot lifts them
+	for userID, muteInfo := range data.MutedUsers {
+		for voterID, expiry := range muteInfo.MutedBy {
+			if now.After(expiry) {
+				delete(muteInfo.MutedBy, voterID)
+			}
+		}
+		if len(muteInfo.MutedBy) == 0 && !muteInfo.IsGloballyMuted {
+			delete(data.MutedUsers, userID)
+		}
+	}
+	for guildID, bans := range data.VoteBans {
+		for userID, ban := range bans {
+			if !ban.Until.IsZero() && now.After(ban.Until) {
+				delete(bans, userID)
+			}
+		}
+		if len(bans) == 0 {
+			delete(data.VoteBans, guildID)
+		}
+	}
+	return data, skipped
+}
+
+// recoverMuteData rebuilds the mute data from the audit log when the mute file can't be read.
+// The damaged file is kept next to the new one.
+func recoverMuteData() {
+	backup := fmt.Sprintf("%s.corrupted-%d", muteFile, clock.Now().Unix())
+	if err := os.Rename(muteFile, backup); err != nil {
+		slog.Error("Error keeping the damaged mute file", "file", muteFile, "error", err)
+		return
+	}
+
+	location, err := logLocation()
+	if err != nil {
+		slog.Error("Error rebuilding mute data", "error", err)
+		return
+	}
+	events, err := readAuditEvents("logs", location)
+	if err != nil {
+		slog.Error("Error rebuilding mute data from the audit log", "error", err)
+		return
+	}
+
+	data, skipped := replayEvents(events, clock.Now())
+	muteData = data
+	saveMuteData()
+	slog.Warn("Mute data rebuilt from the audit log", "events", len(events), "skipped", skipped, "backup", backup)
+}
+
+// rebuildState replays the audit log into a new mute file
+func rebuildState(args []string) int {
+	flags := flag.NewFlagSet("rebuild-state", flag.ContinueOnError)
+	dir := flags.String("dir", "logs", "directory of the audit log")
+	output := flags.String("output", muteFile, "mute file to write")
+	timezone := flags.String("tz", "", "time zone of the log files, log_timezone of config.json by default")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	// The configuration is optional, it gives the time zone and the guild settings
+	if err := readConfig(); err == nil {
+		loadGuildConfigs()
+	}
+	if *timezone != "" {
+		config.LogTimezone = *timezone
+	}
+	location, err := logLocation()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	events, err := readAuditEvents(*dir, location)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading the audit log: %v\n", err)
+		return 1
+	}
+	if len(events) == 0 {
+		fmt.Fprintf(os.Stderr, "No audit events found in %s\n", *dir)
+		return 1
+	}
+
+	data, skipped := replayEvents(events, clock.Now())
+
+	// Keep the previous file
+	if _, err := os.Stat(*output); err == nil {
+		backup := fmt.Sprintf("%s.bak-%d", *output, clock.Now().Unix())
+		if err := os.Rename(*output, backup); err != nil {
+			fmt.Fprintf(os.Stderr, "Error keeping the previous mute file: %v\n", err)
+			return 1
+		}
+		fmt.Printf("Previous mute file saved as %s\n", backup)
+	}
+
+	muteData = data
+	muteFile = *output
+	saveMuteData()
+
+	mutes, votes, bans := 0, 0, 0
+	for _, muteInfo := range data.MutedUsers {
+		if muteInfo.IsGloballyMuted {
+			mutes++
+		}
+		votes += len(muteInfo.MutedBy)
+	}
+	for _, guildBans := range data.VoteBans {
+		bans += len(guildBans)
+	}
+
+	fmt.Printf("Replayed %d events from %s (%d skipped without user IDs)\n", len(events), *dir, skipped)
+	fmt.Printf("Wrote %s: %d mutes, %d active votes, %d vote bans\n", *output, mutes, votes, bans)
+	fmt.Println("Appeals and juries aren't rebuilt.")
+	return 0
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/tu-usuario/DiscMuteBot/audit"
+)
+
+// writeAuditLog writes the history to the daily files of a new sink of the format and returns their directory
+func writeAuditLog(t *testing.T, format string) string {
+	t.Helper()
+	dir := t.TempDir()
+	var sink audit.Sink = audit.NewJSONLSink(dir, 0644, time.UTC)
+	if format == "csv" {
+		sink = audit.NewCSVSink(dir, 0, 0644, time.UTC)
+	}
+	for _, event := range history {
+		if err := sink.Write(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestReplayKeepsModeratorMutes(t *testing.T) {
+	discord := setup(t)
+	settings := defaultSettings(testGuild)
+	settings.SanctionMode = SANCTION_TIMEOUT
+	guildConfigs[testGuild] = settings
+	// bob already has a server mute from a moderator, carol doesn't
+	manual := discord.addMember("bob")
+	discord.joinVoice(manual.ID, true)
+	mute(t, discord, manual)
+	own := discord.addMember("carol")
+	discord.joinVoice(own.ID, false)
+	mute(t, discord, own)
+
+	var replayed MuteData
+	for _, format := range []string{"jsonl", "csv"} {
+		events, err := readAuditEvents(writeAuditLog(t, format), time.UTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, skipped := replayEvents(events, clock.Now())
+
+		if skipped != 0 {
+			t.Errorf("%s: skipped = %d", format, skipped)
+		}
+		if muteInfo := data.MutedUsers[manual.ID]; !muteInfo.IsGloballyMuted || !muteInfo.ManualMute || !muteInfo.TimedOut {
+			t.Errorf("%s: bob = %+v, want a timed out mute that keeps the moderator mute", format, muteInfo)
+		}
+		if muteInfo := data.MutedUsers[own.ID]; !muteInfo.IsGloballyMuted || muteInfo.ManualMute || !muteInfo.TimedOut {
+			t.Errorf("%s: carol = %+v, want a timed out mute of the bot", format, muteInfo)
+		}
+		for _, event := range events {
+			if event.Type == "MUTE" && len(event.Voters) != 1 {
+				t.Errorf("%s: voters of the mute of %s = %v", format, event.Target, event.Voters)
+			}
+		}
+		replayed = data
+	}
+
+	// The state rebuilt from the CSV files, the default sink, lifts only the mute the bot applied
+	muteData = replayed
+	unmuteUser(discord, testGuild, manual.ID)
+	unmuteUser(discord, testGuild, own.ID)
+	if !discord.serverMuted(manual.ID) {
+		t.Error("moderator mute lifted")
+	}
+	if discord.serverMuted(own.ID) {
+		t.Error("bot mute not lifted")
+	}
+}
+
+func TestReadAuditEventsMergesFormats(t *testing.T) {
+	setup(t)
+	dir := t.TempDir()
+	csvSink := audit.NewCSVSink(dir, 0, 0644, time.UTC)
+	jsonlSink := audit.NewJSONLSink(dir, 0644, time.UTC)
+	vote := audit.Event{Type: "VOTE", GuildID: testGuild, InitiatorID: "1", Initiator: "alice", TargetID: "2", Target: "bob",
+		Time: clock.Now().Add(-24 * time.Hour)}
+	mute := audit.Event{Type: "MUTE", GuildID: testGuild, Initiator: "System", TargetID: "2", Target: "bob",
+		Time: clock.Now().Add(1500 * time.Millisecond), Reason: "spam"}
+
+	// Only the CSV files were enabled for the vote
+	if err := csvSink.Write(vote); err != nil {
+		t.Fatal(err)
+	}
+	for _, sink := range []audit.Sink{csvSink, jsonlSink} {
+		if err := sink.Write(mute); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	events, err := readAuditEvents(dir, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Type != "VOTE" || events[1].Type != "MUTE" {
+		t.Fatalf("events = %+v, want the vote of the CSV files and the mute once", events)
+	}
+	if !events[1].Time.Equal(mute.Time) {
+		t.Errorf("mute time = %v, want %v from the JSON Lines files", events[1].Time, mute.Time)
+	}
+}
+
+func TestRecoverMuteDataUsesTheClock(t *testing.T) {
+	setup(t)
+	config.LogTimezone = "UTC"
+	t.Cleanup(func() {
+		config.LogTimezone = ""
+		os.RemoveAll("logs")
+	})
+
+	// The vote is still valid for the bot clock, long expired for the real time
+	sink := audit.NewJSONLSink("logs", 0644, time.UTC)
+	err := sink.Write(audit.Event{Type: "VOTE", GuildID: testGuild, InitiatorID: "1", Initiator: "alice", TargetID: "2",
+		Target: "bob", Time: clock.Now(), ExpiresAt: clock.Now().Add(VOTE_DURATION)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(muteFile, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	loadMuteData()
+
+	if votes := muteData.MutedUsers["2"].MutedBy; len(votes) != 1 {
+		t.Errorf("votes of bob = %v, want the vote of alice", votes)
+	}
+}
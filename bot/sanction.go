@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// Sanctions of a mute
+const (
+	SANCTION_VOICE   = "voice"   // Server mute in voice channels
+	SANCTION_TIMEOUT = "timeout" // Server mute and a Discord timeout until the mute ends
+)
+
+// applyTimeout times the member out until the end of the mute, if the guild sanctions mutes with a timeout.
+// Needs the Moderate Members permission. Returns false if Discord rejected the timeout.
+func applyTimeout(s Discord, guildID, userID string, muteInfo *MuteInfo) bool {
+	if guildSettings(guildID).SanctionMode != SANCTION_TIMEOUT {
+		return true
+	}
+
+	until := muteInfo.MuteExpiry
+	err := s.GuildMemberTimeout(guildID, userID, &until)
+	if err != nil {
+		slog.Error("Error timing out user", "guild_id", guildID, "user_id", userID, "error", err)
+		return false
+	}
+	muteInfo.TimedOut = true
+	return true
+}
+
+// endTimeout removes the timeout of a mute that ends before its time. Discord ends it by itself
+// when the mute expires.
+func endTimeout(s Discord, guildID, userID string, muteInfo *MuteInfo) {
+	if !muteInfo.TimedOut {
+		return
+	}
+	muteInfo.TimedOut = false
+	if !clock.Now().Before(muteInfo.MuteExpiry) {
+		return
+	}
+
+	if err := s.GuildMemberTimeout(guildID, userID, nil); err != nil {
+		slog.Error("Error removing timeout", "guild_id", guildID, "user_id", userID, "error", err)
+		return
+	}
+	slog.Info("Timeout removed with the mute", "guild_id", guildID, "user_id", userID)
+}
+
+// timeoutNote tells in the announcement of a mute that the member was also timed out
+func timeoutNote(guildID string, muteInfo MuteInfo, applied bool) string {
+	if guildSettings(guildID).SanctionMode != SANCTION_TIMEOUT {
+		return ""
+	}
+	if !applied {
+		return "\n⚠️ The timeout couldn't be applied, the bot needs the Moderate Members permission."
+	}
+	return fmt.Sprintf("\n⏱️ Timed out until <t:%d:t>.", muteInfo.MuteExpiry.Unix())
+}
@@ -0,0 +1,614 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/tu-usuario/DiscMuteBot/audit"
+)
+
+// GuildSettings holds the options that guild admins can change at runtime
+type GuildSettings struct {
+	VotesNeeded     int      `json:"votes_needed"`
+	VoteMinutes     int      `json:"vote_minutes"`
+	MuteMinutes     int      `json:"mute_minutes"`
+	SanctionMode    string   `json:"sanction_mode"`
+	JuryMode        bool     `json:"jury_mode"`
+	JurySize        int      `json:"jury_size"`
+	JuryMinutes     int      `json:"jury_minutes"`
+	AppealChannel   string   `json:"appeal_channel"`
+	AnnounceChannel string   `json:"announce_channel"`
+	ImmuneRoles     []string `json:"immune_roles"`
+	LogChannel      string   `json:"log_channel"`
+	LogVerbosity    string   `json:"log_verbosity"`
+	Language        string   `json:"language"`
+
+	// Roles and permission that can use each privileged command
+	CommandRoles       map[string][]string `json:"command_roles,omitempty"`
+	CommandPermissions map[string]string   `json:"command_permissions,omitempty"`
+}
+
+func (g GuildSettings) VoteDuration() time.Duration {
+	return time.Duration(g.VoteMinutes) * time.Minute
+}
+
+func (g GuildSettings) MuteDuration() time.Duration {
+	return time.Duration(g.MuteMinutes) * time.Minute
+}
+
+func (g GuildSettings) JuryDuration() time.Duration {
+	return time.Duration(g.JuryMinutes) * time.Minute
+}
+
+// configOption describes a setting that can be changed with !config set
+type configOption struct {
+	Key         string
+	Description string
+	// set validates the value and stores it in the settings
+	set func(settings *GuildSettings, guild *discordgo.Guild, value string) error
+	get func(settings GuildSettings) string
+	// reset copies the value of the defaults, which may be outside of the range accepted by set
+	reset func(settings *GuildSettings, defaults GuildSettings)
+}
+
+var (
+	guildConfigs    = make(map[string]GuildSettings)
+	guildConfigFile = "guild_config.json"
+
+	channelMention = regexp.MustCompile(`^<#(\d+)>$`)
+	roleMention    = regexp.MustCompile(`^<@&(\d+)>$`)
+	snowflake      = regexp.MustCompile(`^\d+$`)
+)
+
+var configOptions = []configOption{
+	{
+		Key:         "votes_needed",
+		Description: "Votes needed to mute a user (1-100)",
+		set: func(settings *GuildSettings, guild *discordgo.Guild, value string) (err error) {
+			settings.VotesNeeded, err = parseIntRange(value, 1, 100)
+			return err
+		},
+		get:   func(settings GuildSettings) string { return strconv.Itoa(settings.VotesNeeded) },
+		reset: func(settings *GuildSettings, defaults GuildSettings) { settings.VotesNeeded = defaults.VotesNeeded },
+	},
+	{
+		Key:         "vote_duration",
+		Description: "Minutes a vote lasts (1-1440)",
+		set: func(settings *GuildSettings, guild *discordgo.Guild, value string) (err error) {
+			settings.VoteMinutes, err = parseMinutes(value, 1, 1440)
+			return err
+		},
+		get:   func(settings GuildSettings) string { return formatMinutes(settings.VoteMinutes) },
+		reset: func(settings *GuildSettings, defaults GuildSettings) { settings.VoteMinutes = defaults.VoteMinutes },
+	},
+	{
+		Key:         "mute_duration",
+		Description: "Minutes a mute lasts (1-1440)",
+		set: func(settings *GuildSettings, guild *discordgo.Guild, value string) (err error) {
+			settings.MuteMinutes, err = parseMinutes(value, 1, 1440)
+			return err
+		},
+		get:   func(settings GuildSettings) string { return formatMinutes(settings.MuteMinutes) },
+		reset: func(settings *GuildSettings, defaults GuildSettings) { settings.MuteMinutes = defaults.MuteMinutes },
+	},
+	{
+		Key:         "sanction_mode",
+		Description: "What a mute does (voice: server mute in voice channels, timeout: also a Discord timeout)",
+		set: func(settings *GuildSettings, guild *discordgo.Guild, value string) error {
+			switch value {
+			case SANCTION_VOICE, SANCTION_TIMEOUT:
+				settings.SanctionMode = value
+				return nil
+			}
+			return fmt.Errorf("use %s or %s", SANCTION_VOICE, SANCTION_TIMEOUT)
+		},
+		get: func(settings GuildSettings) string {
+			if settings.SanctionMode == "" {
+				return SANCTION_VOICE
+			}
+			return settings.SanctionMode
+		},
+		reset: func(settings *GuildSettings, defaults GuildSettings) { settings.SanctionMode = defaults.SanctionMode },
+	},
+	{
+		Key:         "jury_mode",
+		Description: "Require a jury to confirm mutes (on/off)",
+		set: func(settings *GuildSettings, guild *discordgo.Guild, value string) (err error) {
+			settings.JuryMode, err = parseSwitch(value)
+			return err
+		},
+		get:   func(settings GuildSettings) string { return formatSwitch(settings.JuryMode) },
+		reset: func(settings *GuildSettings, defaults GuildSettings) { settings.JuryMode = defaults.JuryMode },
+	},
+	{
+		Key:         "jury_size",
+		Description: "Number of jurors (1-25)",
+		set: func(settings *GuildSettings, guild *discordgo.Guild, value string) (err error) {
+			settings.JurySize, err = parseIntRange(value, 1, 25)
+			return err
+		},
+		get:   func(settings GuildSettings) string { return strconv.Itoa(settings.JurySize) },
+		reset: func(settings *GuildSettings, defaults GuildSettings) { settings.JurySize = defaults.JurySize },
+	},
+	{
+		Key:         "jury_duration",
+		Description: "Minutes the jury has to decide (1-60)",
+		set: func(settings *GuildSettings, guild *discordgo.Guild, value string) (err error) {
+			settings.JuryMinutes, err = parseMinutes(value, 1, 60)
+			return err
+		},
+		get:   func(settings GuildSettings) string { return formatMinutes(settings.JuryMinutes) },
+		reset: func(settings *GuildSettings, defaults GuildSettings) { settings.JuryMinutes = defaults.JuryMinutes },
+	},
+	{
+		Key:         "appeal_channel",
+		Description: "Channel where appeals are posted (#channel or none)",
+		set: func(settings *GuildSettings, guild *discordgo.Guild, value string) (err error) {
+			settings.AppealChannel, err = parseChannel(guild, value)
+			return err
+		},
+		get:   func(settings GuildSettings) string { return formatChannel(settings.AppealChannel) },
+		reset: func(settings *GuildSettings, defaults GuildSettings) { settings.AppealChannel = defaults.AppealChannel },
+	},
+	{
+		Key:         "announce_channel",
+		Description: "Channel where mutes are announced (#channel or none to use the command channel)",
+		set: func(settings *GuildSettings, guild *discordgo.Guild, value string) (err error) {
+			settings.AnnounceChannel, err = parseChannel(guild, value)
+			return err
+		},
+		get: func(settings GuildSettings) string { return formatChannel(settings.AnnounceChannel) },
+		reset: func(settings *GuildSettings, defaults GuildSettings) {
+			settings.AnnounceChannel = defaults.AnnounceChannel
+		},
+	},
+	{
+		Key:         "immune_roles",
+		Description: "Roles that can't be voted (@role @role... or none)",
+		set: func(settings *GuildSettings, guild *discordgo.Guild, value string) (err error) {
+			settings.ImmuneRoles, err = parseRoles(guild, value)
+			return err
+		},
+		get:   func(settings GuildSettings) string { return formatRoles(settings.ImmuneRoles) },
+		reset: func(settings *GuildSettings, defaults GuildSettings) { settings.ImmuneRoles = defaults.ImmuneRoles },
+	},
+	{
+		Key:         "log_channel",
+		Description: "Channel that receives every moderation event (#channel or none)",
+		set: func(settings *GuildSettings, guild *discordgo.Guild, value string) (err error) {
+			settings.LogChannel, err = parseChannel(guild, value)
+			return err
+		},
+		get:   func(settings GuildSettings) string { return formatChannel(settings.LogChannel) },
+		reset: func(settings *GuildSettings, defaults GuildSettings) { settings.LogChannel = defaults.LogChannel },
+	},
+	{
+		Key:         "log_verbosity",
+		Description: "Events sent to the log channel (minimal: no single votes, normal: all, verbose: all with voters)",
+		set: func(settings *GuildSettings, guild *discordgo.Guild, value string) error {
+			switch value {
+			case LOG_MINIMAL, LOG_NORMAL, LOG_VERBOSE:
+				settings.LogVerbosity = value
+				return nil
+			}
+			return fmt.Errorf("use %s, %s or %s", LOG_MINIMAL, LOG_NORMAL, LOG_VERBOSE)
+		},
+		get: func(settings GuildSettings) string {
+			if settings.LogVerbosity == "" {
+				return LOG_NORMAL
+			}
+			return settings.LogVerbosity
+		},
+		reset: func(settings *GuildSettings, defaults GuildSettings) { settings.LogVerbosity = defaults.LogVerbosity },
+	},
+	{
+		Key:         "language",
+		Description: "Language of the vote and mute messages (en or es)",
+		set: func(settings *GuildSettings, guild *discordgo.Guild, value string) error {
+			switch value {
+			case LANG_EN, LANG_ES:
+				settings.Language = value
+				return nil
+			}
+			return fmt.Errorf("use %s or %s", LANG_EN, LANG_ES)
+		},
+		get: func(settings GuildSettings) string {
+			if settings.Language == "" {
+				return LANG_EN
+			}
+			return settings.Language
+		},
+		reset: func(settings *GuildSettings, defaults GuildSettings) { settings.Language = defaults.Language },
+	},
+}
+
+// defaultSettings returns the settings of a guild that hasn't changed anything
+func defaultSettings(guildID string) GuildSettings {
+	settings := GuildSettings{
+		VotesNeeded:   VOTES_NEEDED,
+		VoteMinutes:   int(VOTE_DURATION.Minutes()),
+		MuteMinutes:   int(MUTE_DURATION.Minutes()),
+		JuryMode:      config.JuryMode,
+		JurySize:      JURY_SIZE,
+		JuryMinutes:   int(JURY_DURATION.Minutes()),
+		AppealChannel: config.AppealChannels[guildID],
+		SanctionMode:  SANCTION_VOICE,
+		LogVerbosity:  LOG_NORMAL,
+		Language:      LANG_EN,
+	}
+
+	// Values of config.json are the defaults for every guild
+	if config.JurySize > 0 {
+		settings.JurySize = config.JurySize
+	}
+	if config.JuryMinutes > 0 {
+		settings.JuryMinutes = config.JuryMinutes
+	}
+	return settings
+}
+
+// guildSettings returns the current settings of the guild
+func guildSettings(guildID string) GuildSettings {
+	settings, exists := guildConfigs[guildID]
+	if !exists {
+		return defaultSettings(guildID)
+	}
+	return settings
+}
+
+func findConfigOption(key string) (configOption, bool) {
+	for _, option := range configOptions {
+		if option.Key == key {
+			return option, true
+		}
+	}
+	return configOption{}, false
+}
+
+// handleConfig processes the !config command
+func handleConfig(s Discord, m *discordgo.MessageCreate) {
+	args := strings.Fields(strings.TrimPrefix(m.Content, "!config"))
+	for _, chunk := range splitMessage(runConfigCommand(s, m.GuildID, m.Author, args)) {
+		s.ChannelMessageSend(m.ChannelID, chunk)
+	}
+}
+
+// runConfigCommand executes a config subcommand and returns the answer for the admin.
+// Shared by !config and /config.
+func runConfigCommand(s Discord, guildID string, user *discordgo.User, args []string) string {
+	if len(args) == 0 || args[0] == "view" {
+		return configSummary(guildID)
+	}
+
+	switch args[0] {
+	case "set":
+		if len(args) < 3 {
+			return "⚠️ Usage: `!config set <option> <value>`"
+		}
+		return setConfigOption(s, guildID, user, args[1], strings.Join(args[2:], " "))
+	case "reset":
+		if len(args) < 2 {
+			return "⚠️ Usage: `!config reset <option>`"
+		}
+		return resetConfigOption(guildID, user, args[1])
+	}
+
+	return "⚠️ Unknown subcommand. Use `!config`, `!config set <option> <value>` or `!config reset <option>`"
+}
+
+func setConfigOption(s Discord, guildID string, user *discordgo.User, key, value string) string {
+	option, exists := findConfigOption(key)
+	if !exists {
+		return fmt.Sprintf("⚠️ Unknown option `%s`. Use `!config` to see the available options.", key)
+	}
+
+	guild, err := s.StateGuild(guildID)
+	if err != nil {
+		slog.Error("Error getting server information", "guild_id", guildID, "error", err)
+		return "❌ Error getting server information."
+	}
+
+	settings := guildSettings(guildID)
+	oldValue := option.get(settings)
+
+	err = option.set(&settings, guild, value)
+	if err != nil {
+		return fmt.Sprintf("❌ Invalid value for `%s`: %v", key, err)
+	}
+
+	newValue := option.get(settings)
+	storeSettings(guildID, settings, user, key, oldValue, newValue)
+
+	return fmt.Sprintf("⚙️ `%s` changed from **%s** to **%s**", key, oldValue, newValue)
+}
+
+func resetConfigOption(guildID string, user *discordgo.User, key string) string {
+	option, exists := findConfigOption(key)
+	if !exists {
+		return fmt.Sprintf("⚠️ Unknown option `%s`. Use `!config` to see the available options.", key)
+	}
+
+	settings := guildSettings(guildID)
+	oldValue := option.get(settings)
+
+	option.reset(&settings, defaultSettings(guildID))
+	newValue := option.get(settings)
+
+	storeSettings(guildID, settings, user, key, oldValue, newValue)
+
+	return fmt.Sprintf("⚙️ `%s` reset to **%s**", key, newValue)
+}
+
+// storeSettings persists the settings and records the change
+func storeSettings(guildID string, settings GuildSettings, user *discordgo.User, key, oldValue, newValue string) {
+	guildConfigs[guildID] = settings
+	saveGuildConfigs()
+
+	recordEvent(audit.Event{
+		Type:        "CONFIG",
+		GuildID:     guildID,
+		InitiatorID: user.ID,
+		Initiator:   user.Username,
+		Key:         key,
+		OldValue:    oldValue,
+		NewValue:    newValue,
+	})
+}
+
+func configSummary(guildID string) string {
+	settings := guildSettings(guildID)
+
+	var msg strings.Builder
+	msg.WriteString("⚙️ **Server configuration:**\n\n")
+	for _, option := range configOptions {
+		msg.WriteString(fmt.Sprintf("`%s` = **%s**\n   %s\n", option.Key, option.get(settings), option.Description))
+	}
+	msg.WriteString("\nUse `!config set <option> <value>` to change an option or `!config reset <option>` to restore its default.")
+	return msg.String()
+}
+
+func parseIntRange(value string, min, max int) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a number", value)
+	}
+	if n < min || n > max {
+		return 0, fmt.Errorf("must be between %d and %d", min, max)
+	}
+	return n, nil
+}
+
+// parseMinutes accepts a number of minutes or a duration like 90s, 15m, 2h or 7d
+func parseMinutes(value string, min, max int) (int, error) {
+	if n, err := strconv.Atoi(value); err == nil {
+		return parseIntRange(strconv.Itoa(n), min, max)
+	}
+
+	if days, err := strconv.Atoi(strings.TrimSuffix(value, "d")); err == nil && strings.HasSuffix(value, "d") {
+		return parseIntRange(strconv.Itoa(days*24*60), min, max)
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a duration, use minutes or values like 15m or 2h", value)
+	}
+	if d%time.Minute != 0 {
+		return 0, fmt.Errorf("must be a whole number of minutes")
+	}
+	return parseIntRange(strconv.Itoa(int(d.Minutes())), min, max)
+}
+
+func parseSwitch(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "on", "true", "yes", "enabled":
+		return true, nil
+	case "off", "false", "no", "disabled":
+		return false, nil
+	}
+	return false, fmt.Errorf("use on or off")
+}
+
+func parseChannel(guild *discordgo.Guild, value string) (string, error) {
+	if value == "none" || value == "" {
+		return "", nil
+	}
+
+	channelID := value
+	if match := channelMention.FindStringSubmatch(value); match != nil {
+		channelID = match[1]
+	}
+	if !snowflake.MatchString(channelID) {
+		return "", fmt.Errorf("mention a channel with #")
+	}
+
+	for _, channel := range guild.Channels {
+		if channel.ID == channelID && channel.Type == discordgo.ChannelTypeGuildText {
+			return channelID, nil
+		}
+	}
+	return "", fmt.Errorf("the channel must be a text channel of this server")
+}
+
+func parseRoles(guild *discordgo.Guild, value string) ([]string, error) {
+	if value == "none" || value == "" {
+		return nil, nil
+	}
+
+	var roles []string
+	for _, field := range strings.Fields(value) {
+		roleID := field
+		if match := roleMention.FindStringSubmatch(field); match != nil {
+			roleID = match[1]
+		}
+		if !snowflake.MatchString(roleID) {
+			return nil, fmt.Errorf("mention the roles with @")
+		}
+
+		found := false
+		for _, role := range guild.Roles {
+			if role.ID == roleID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("role %s doesn't exist in this server", roleID)
+		}
+		roles = append(roles, roleID)
+	}
+	return roles, nil
+}
+
+func formatMinutes(minutes int) string {
+	return fmt.Sprintf("%d minutes", minutes)
+}
+
+func formatSwitch(enabled bool) string {
+	if enabled {
+		return "on"
+	}
+	return "off"
+}
+
+func formatChannel(channelID string) string {
+	if channelID == "" {
+		return "none"
+	}
+	return "<#" + channelID + ">"
+}
+
+func formatRoles(roles []string) string {
+	if len(roles) == 0 {
+		return "none"
+	}
+	var mentions []string
+	for _, roleID := range roles {
+		mentions = append(mentions, "<@&"+roleID+">")
+	}
+	return strings.Join(mentions, " ")
+}
+
+// configCommand is the /config slash command, registered when the bot is ready
+func configCommand() *discordgo.ApplicationCommand {
+	adminPermission := int64(discordgo.PermissionManageServer)
+	dmPermission := false
+
+	var choices []*discordgo.ApplicationCommandOptionChoice
+	for _, option := range configOptions {
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{Name: option.Key, Value: option.Key})
+	}
+
+	return &discordgo.ApplicationCommand{
+		Name:                     "config",
+		Description:              "View or change the mute system configuration",
+		DefaultMemberPermissions: &adminPermission,
+		DMPermission:             &dmPermission,
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "view",
+				Description: "Show the current configuration",
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "set",
+				Description: "Change an option",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "option",
+						Description: "Option to change",
+						Required:    true,
+						Choices:     choices,
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "value",
+						Description: "New value",
+						Required:    true,
+					},
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "reset",
+				Description: "Restore the default value of an option",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "option",
+						Description: "Option to reset",
+						Required:    true,
+						Choices:     choices,
+					},
+				},
+			},
+		},
+	}
+}
+
+// handleConfigSlash processes the /config slash command
+func handleConfigSlash(s Discord, i *discordgo.InteractionCreate) {
+	if !requireInteractionPermission(s, i, "config") {
+		return
+	}
+
+	var args []string
+	if data := i.ApplicationCommandData(); len(data.Options) > 0 {
+		subcommand := data.Options[0]
+		args = append(args, subcommand.Name)
+		for _, option := range subcommand.Options {
+			args = append(args, option.StringValue())
+		}
+	}
+
+	chunks := splitMessage(runConfigCommand(s, i.GuildID, interactionUser(i), args))
+	respondEphemeral(s, i, chunks[0])
+	for _, chunk := range chunks[1:] {
+		_, err := s.FollowupMessageCreate(i.Interaction, false, &discordgo.WebhookParams{
+			Content: chunk,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		})
+		if err != nil {
+			slog.Error("Error sending followup message", "guild_id", i.GuildID, "command", "/config", "error", err)
+		}
+	}
+}
+
+func loadGuildConfigs() {
+	data, err := os.ReadFile(guildConfigFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			slog.Error("Error reading guild configuration file", "file", guildConfigFile, "error", err)
+		}
+		return
+	}
+
+	err = json.Unmarshal(data, &guildConfigs)
+	if err != nil {
+		slog.Error("Error deserializing guild configuration", "file", guildConfigFile, "error", err)
+	}
+}
+
+func saveGuildConfigs() {
+	data, err := json.MarshalIndent(guildConfigs, "", "    ")
+	if err != nil {
+		slog.Error("Error serializing guild configuration", "error", err)
+		recordPersistence(guildConfigFile, err)
+		return
+	}
+
+	err = os.WriteFile(guildConfigFile, data, 0644)
+	if err != nil {
+		slog.Error("Error saving guild configuration file", "file", guildConfigFile, "error", err)
+	}
+	recordPersistence(guildConfigFile, err)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestConfigResetOutOfRangeDefault(t *testing.T) {
+	discord := setup(t)
+	owner := discord.addMember(testOwner)
+	// config.json allows more jurors than !config set
+	config.JurySize = 50
+	t.Cleanup(func() { config.JurySize = 0 })
+
+	settings := defaultSettings(testGuild)
+	settings.JurySize = 3
+	guildConfigs[testGuild] = settings
+
+	answer := runConfigCommand(discord, testGuild, owner, []string{"reset", "jury_size"})
+	if !strings.Contains(answer, "reset to **50**") {
+		t.Errorf("answer = %q", answer)
+	}
+	if size := guildSettings(testGuild).JurySize; size != 50 {
+		t.Errorf("jury size = %d, want 50", size)
+	}
+}
+
+func TestConfigResetCommandRoles(t *testing.T) {
+	discord := setup(t)
+	owner := discord.addMember(testOwner)
+	discord.guild.Roles = append(discord.guild.Roles, &discordgo.Role{ID: "10", Name: "Helpers"})
+
+	runConfigCommand(discord, testGuild, owner, []string{"set", "clean_roles", "<@&10>"})
+	if roles := guildSettings(testGuild).CommandRoles["clean"]; len(roles) != 1 {
+		t.Fatalf("clean roles = %v", roles)
+	}
+
+	runConfigCommand(discord, testGuild, owner, []string{"reset", "clean_roles"})
+	if roles, exists := guildSettings(testGuild).CommandRoles["clean"]; exists {
+		t.Errorf("clean roles = %v after reset", roles)
+	}
+}
+
+func TestLanguage(t *testing.T) {
+	discord := setup(t)
+	owner := discord.addMember(testOwner)
+	target := discord.addMember("bob")
+	setVotesNeeded(2)
+
+	runConfigCommand(discord, testGuild, owner, []string{"set", "language", "es"})
+	messageCreate(discord, message(discord.addMember("alice"), "!mute @bob", target))
+
+	if msg := discord.lastMessage(testChannel); !strings.Contains(msg, "Voto registrado contra bob. Votos actuales: 1/2") {
+		t.Errorf("answer = %q, want it in Spanish", msg)
+	}
+}
+
+func TestSpanishKeepsFormatVerbs(t *testing.T) {
+	verbs := regexp.MustCompile(`%[a-z]`)
+	for english, translated := range spanish {
+		if want, got := verbs.FindAllString(english, -1), verbs.FindAllString(translated, -1); strings.Join(want, "") != strings.Join(got, "") {
+			t.Errorf("%q has the verbs %v, want %v", translated, got, want)
+		}
+	}
+}
+
+func TestConfigChangeEvent(t *testing.T) {
+	discord := setup(t)
+	owner := discord.addMember(testOwner)
+
+	runConfigCommand(discord, testGuild, owner, []string{"set", "votes_needed", "7"})
+
+	event := history[len(history)-1]
+	if event.Type != "CONFIG" || event.Key != "votes_needed" || event.NewValue != "7" || event.Target != "" {
+		t.Fatalf("event = %+v, want the option and values without a target", event)
+	}
+	if description := modLogEmbed(event, false).Description; description != "`votes_needed`: "+event.OldValue+" -> 7" {
+		t.Errorf("mod-log description = %q", description)
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Time to finish the running handlers and flush the stored data. Docker kills the
+// container 10 seconds after SIGTERM, unless config.json sets another value.
+const SHUTDOWN_TIMEOUT = 8 * time.Second
+
+var (
+	shuttingDown atomic.Bool
+	// Held by the handlers and timers while they read or change the mute data, the guild
+	// configs and the history, so they run one at a time. The shutdown takes it to wait
+	// for the running one to finish.
+	workLock sync.Mutex
+
+	resumeOnce sync.Once
+)
+
+// beginWork waits for the handler or timer running and locks the state for the caller.
+// Returns false once the shutdown started, the caller must then do nothing.
+func beginWork() bool {
+	if shuttingDown.Load() {
+		return false
+	}
+	workLock.Lock()
+	if shuttingDown.Load() {
+		workLock.Unlock()
+		return false
+	}
+	return true
+}
+
+func endWork() {
+	workLock.Unlock()
+}
+
+// resumeTimers schedules the unmutes, jury deadlines and queued mute changes stored by a previous process.
+// Mutes and juries that expired while the bot was stopped are resolved right away.
+func resumeTimers(s Discord) {
+	resumeOnce.Do(func() {
+		mutes, juries := 0, 0
+		for userID, muteInfo := range muteData.MutedUsers {
+			if !muteInfo.IsGloballyMuted {
+				continue
+			}
+			scheduleUnmute(s, muteInfo.GuildID, userID, max(timeUntil(muteInfo.MuteExpiry), 0))
+			mutes++
+		}
+		for _, jury := range muteData.Juries {
+			if jury.Result != JURY_PENDING {
+				continue
+			}
+			scheduleJuryExpiry(s, jury)
+			juries++
+		}
+		slog.Info("Resumed pending timers", "unmutes", mutes, "juries", juries)
+		startActionQueue(s)
+	})
+}
+
+// shutdown stops accepting commands, waits for the running handlers and flushes
+// the stored data and the audit log before the deadline
+func shutdown(dg *discordgo.Session, server *http.Server) {
+	timeout := SHUTDOWN_TIMEOUT
+	if config.ShutdownTimeoutSeconds > 0 {
+		timeout = time.Duration(config.ShutdownTimeoutSeconds) * time.Second
+	}
+	deadline := time.Now().Add(timeout)
+	slog.Info("Shutting down", "timeout", timeout)
+
+	// New commands, voice updates and timers are ignored from now on,
+	// the timers are resumed by the next process from the stored data
+	shuttingDown.Store(true)
+	idle := waitForWork(deadline)
+
+	flushed := make(chan struct{})
+	go func() {
+		defer close(flushed)
+
+		flushState(idle, deadline)
+
+		if server != nil {
+			ctx, cancel := context.WithDeadline(context.Background(), deadline)
+			defer cancel()
+			if err := server.Shutdown(ctx); err != nil {
+				slog.Error("Error stopping the HTTP server", "error", err)
+			}
+		}
+		if err := dg.Close(); err != nil {
+			slog.Error("Error closing the Discord session", "error", err)
+		}
+	}()
+	select {
+	case <-flushed:
+		slog.Info("Shutdown complete")
+	case <-time.After(time.Until(deadline)):
+		slog.Error("Shutdown deadline reached before flushing everything")
+	}
+}
+
+// waitForWork takes the work lock for good once the running handler or timer finishes.
+// Returns false if it's still running at the deadline.
+func waitForWork(deadline time.Time) bool {
+	idle := make(chan struct{})
+	go func() {
+		workLock.Lock()
+		close(idle)
+	}()
+	select {
+	case <-idle:
+		return true
+	case <-time.After(time.Until(deadline)):
+		return false
+	}
+}
+
+// flushState saves the mute data and the guild configs and closes the audit log. The data isn't
+// saved while a handler may still be changing it, the files keep the last change the handlers saved.
+// The events the webhook hasn't sent by the deadline are dropped.
+func flushState(idle bool, deadline time.Time) {
+	if idle {
+		saveMuteData()
+		saveGuildConfigs()
+	} else {
+		slog.Warn("Handlers still running at the shutdown deadline, the stored data is left as they last saved it")
+	}
+
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+	if err := auditLog.Shutdown(ctx); err != nil {
+		slog.Error("Error closing the audit log", "error", err)
+	}
+}
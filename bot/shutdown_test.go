@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/tu-usuario/DiscMuteBot/audit"
+)
+
+// stopShutdown undoes the shutdown of a test once the handler it waited for ends,
+// unlocking the work lock that waitForWork takes
+func stopShutdown() {
+	for workLock.TryLock() {
+		// waitForWork hasn't taken it yet
+		workLock.Unlock()
+		time.Sleep(time.Millisecond)
+	}
+	workLock.Unlock()
+	shuttingDown.Store(false)
+}
+
+func TestActionQueueRunsDuringVotes(t *testing.T) {
+	discord := setup(t)
+	setVotesNeeded(100)
+	target := discord.addMember("bob")
+	discord.joinVoice(target.ID, false)
+	stuck := discord.addMember("carol")
+	discord.joinVoice(stuck.ID, true)
+	discord.muteHook = func(guildID, userID string, mute bool) error {
+		if userID == stuck.ID {
+			return restError(http.StatusBadGateway, 0)
+		}
+		return nil
+	}
+	queueAction(PendingAction{ID: "1", GuildID: testGuild, UserID: stuck.ID, Attempts: 1, NextAttempt: clock.Now()})
+	resumeTimers(discord)
+
+	// The queue timer runs in its own goroutine, like the handlers of discordgo
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 50; i++ {
+			testClock.Advance(ACTION_QUEUE_INTERVAL)
+		}
+	}()
+	for i := 0; i < 50; i++ {
+		messageCreate(discord, message(discord.addMember(fmt.Sprintf("voter%d", i)), "!mute @bob", target))
+	}
+	<-done
+
+	if votes := len(muteData.MutedUsers[target.ID].MutedBy); votes != 50 {
+		t.Errorf("votes = %d, want 50", votes)
+	}
+	if _, queued := muteData.Actions[actionKey(testGuild, stuck.ID)]; !queued {
+		t.Error("failing unmute no longer queued")
+	}
+}
+
+func TestShutdownWaitsForRunningHandler(t *testing.T) {
+	setup(t)
+	started := make(chan struct{})
+	go func() {
+		beginWork()
+		defer endWork()
+		close(started)
+		time.Sleep(50 * time.Millisecond)
+		muteData.MutedUsers["bob"] = MuteInfo{GuildID: testGuild, MutedBy: map[string]time.Time{"alice": clock.Now()}}
+	}()
+	<-started
+
+	shuttingDown.Store(true)
+	idle := waitForWork(time.Now().Add(5 * time.Second))
+	t.Cleanup(stopShutdown)
+
+	if !idle {
+		t.Fatal("gave up on a handler that ends before the deadline")
+	}
+	if beginWork() {
+		t.Error("work started after the shutdown")
+	}
+	flushState(idle, time.Now().Add(time.Second))
+	data, err := os.ReadFile(muteFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved MuteData
+	if err := json.Unmarshal(data, &saved); err != nil || len(saved.MutedUsers["bob"].MutedBy) != 1 {
+		t.Errorf("saved mute data = %s, want the vote of the handler", data)
+	}
+}
+
+func TestShutdownDeadlineLeavesRunningHandler(t *testing.T) {
+	setup(t)
+	// A handler that doesn't finish before the deadline keeps changing the mute data
+	started := make(chan struct{})
+	stop := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		beginWork()
+		defer endWork()
+		close(started)
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+				muteData.MutedUsers[fmt.Sprint(i)] = MuteInfo{GuildID: testGuild}
+				time.Sleep(100 * time.Microsecond)
+			}
+		}
+	}()
+	<-started
+
+	shuttingDown.Store(true)
+	deadline := time.Now().Add(50 * time.Millisecond)
+	idle := waitForWork(deadline)
+	flushState(idle, deadline)
+	close(stop)
+	<-finished
+	stopShutdown()
+
+	if idle {
+		t.Error("the handler ended before the deadline")
+	}
+	// Saving would have read the mute data while the handler changed it
+	if _, err := os.Stat(muteFile); !os.IsNotExist(err) {
+		t.Errorf("mute data saved during the handler: %v", err)
+	}
+}
+
+func TestShutdownDeadlineDropsWebhookEvents(t *testing.T) {
+	setup(t)
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	previous := auditLog
+	t.Cleanup(func() { auditLog = previous })
+	var err error
+	auditLog, err = audit.New([]string{"webhook"}, audit.Options{WebhookURL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recordEvent(audit.Event{Type: "VOTE", GuildID: testGuild})
+	recordEvent(audit.Event{Type: "VOTE", GuildID: testGuild})
+
+	start := time.Now()
+	flushState(true, start.Add(50*time.Millisecond))
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("flushing took %v, the deadline was 50ms", elapsed)
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/tu-usuario/DiscMuteBot/audit"
+)
+
+const (
+	// Period of !stats when none is given
+	STATS_DEFAULT_PERIOD = 7 * 24 * time.Hour
+	// Users shown in each leaderboard
+	STATS_TOP_SIZE = 5
+)
+
+// GuildStats summarizes the moderation events of a guild in a period
+type GuildStats struct {
+	Votes  int
+	Mutes  int
+	Cleans int
+
+	// Vote campaigns: the votes against a user until they reach the threshold or expire
+	CampaignsReached int
+	CampaignsExpired int
+	// Average time from the first vote to the threshold
+	AverageTimeToThreshold time.Duration
+
+	MostMuted   []StatsEntry
+	MostActive  []StatsEntry
+	PeriodStart time.Time
+}
+
+type StatsEntry struct {
+	UserID   string
+	Username string
+	Count    int
+}
+
+// SuccessRate returns the percentage of finished campaigns that reached the threshold
+func (g GuildStats) SuccessRate() float64 {
+	finished := g.CampaignsReached + g.CampaignsExpired
+	if finished == 0 {
+		return 0
+	}
+	return float64(g.CampaignsReached) * 100 / float64(finished)
+}
+
+// campaign tracks the votes against a user
+type campaign struct {
+	start      time.Time
+	lastExpiry time.Time
+}
+
+// computeStats calculates the statistics of the guild from the events since the given time.
+// Events must be in chronological order.
+func computeStats(events []audit.Event, guildID string, since, now time.Time) GuildStats {
+	stats := GuildStats{PeriodStart: since}
+
+	muted := make(map[string]*StatsEntry)
+	voters := make(map[string]*StatsEntry)
+	campaigns := make(map[string]*campaign)
+	var timeToThreshold time.Duration
+
+	// Campaigns that started before the period are followed but not counted
+	finish := func(targetID string, reached bool, at time.Time) {
+		c := campaigns[targetID]
+		delete(campaigns, targetID)
+		if c.start.Before(since) {
+			return
+		}
+		if reached {
+			stats.CampaignsReached++
+			timeToThreshold += at.Sub(c.start)
+		} else {
+			stats.CampaignsExpired++
+		}
+	}
+
+	for _, event := range events {
+		if event.GuildID != guildID || event.Time.After(now) {
+			continue
+		}
+
+		// The votes of the campaign expired before this event
+		if c, active := campaigns[event.TargetID]; active && event.Time.After(c.lastExpiry) {
+			finish(event.TargetID, false, c.lastExpiry)
+		}
+
+		switch event.Type {
+		case "VOTE":
+			c, active := campaigns[event.TargetID]
+			if !active {
+				c = &campaign{start: event.Time}
+				campaigns[event.TargetID] = c
+			}
+			if event.ExpiresAt.After(c.lastExpiry) {
+				c.lastExpiry = event.ExpiresAt
+			}
+		case "MUTE", "JURY_SELECTED":
+			// Reaching the threshold mutes the user or calls the jury
+			if _, active := campaigns[event.TargetID]; active {
+				finish(event.TargetID, true, event.Time)
+			}
+		case "CLEAN", "FORCE_UNMUTE", "JURY_REJECTED":
+			// The votes were discarded
+			delete(campaigns, event.TargetID)
+		}
+
+		if event.Time.Before(since) {
+			continue
+		}
+
+		switch event.Type {
+		case "VOTE":
+			stats.Votes++
+			addStatsEntry(voters, event.InitiatorID, event.Initiator)
+		case "MUTE", "FORCE_MUTE":
+			stats.Mutes++
+			addStatsEntry(muted, event.TargetID, event.Target)
+		case "CLEAN":
+			stats.Cleans++
+		}
+	}
+
+	// Campaigns whose votes already expired
+	for targetID, c := range campaigns {
+		if now.After(c.lastExpiry) {
+			finish(targetID, false, c.lastExpiry)
+		}
+	}
+
+	if stats.CampaignsReached > 0 {
+		stats.AverageTimeToThreshold = timeToThreshold / time.Duration(stats.CampaignsReached)
+	}
+	stats.MostMuted = topStatsEntries(muted)
+	stats.MostActive = topStatsEntries(voters)
+	return stats
+}
+
+func addStatsEntry(entries map[string]*StatsEntry, userID, username string) {
+	entry, exists := entries[userID]
+	if !exists {
+		entry = &StatsEntry{UserID: userID}
+		entries[userID] = entry
+	}
+	// Keep the most recent name
+	entry.Username = username
+	entry.Count++
+}
+
+func topStatsEntries(entries map[string]*StatsEntry) []StatsEntry {
+	var top []StatsEntry
+	for _, entry := range entries {
+		top = append(top, *entry)
+	}
+	sort.Slice(top, func(a, b int) bool {
+		if top[a].Count != top[b].Count {
+			return top[a].Count > top[b].Count
+		}
+		return top[a].Username < top[b].Username
+	})
+	if len(top) > STATS_TOP_SIZE {
+		top = top[:STATS_TOP_SIZE]
+	}
+	return top
+}
+
+// handleStats processes !stats [period]
+func handleStats(s Discord, m *discordgo.MessageCreate) {
+	now := clock.Now()
+	since := now.Add(-STATS_DEFAULT_PERIOD)
+	periodName := "last 7 days"
+
+	args := commandArgs(m.Content)
+	if len(args) > 0 {
+		if args[0] == "all" {
+			since = time.Time{}
+			periodName = "all time"
+		} else {
+			minutes, err := parseMinutes(args[0], 1, 10*365*24*60)
+			if err != nil {
+				s.ChannelMessageSend(m.ChannelID, "⚠️ Invalid period. Examples: `!stats 24h`, `!stats 30d`, `!stats all`")
+				return
+			}
+			since = now.Add(-time.Duration(minutes) * time.Minute)
+			periodName = "last " + args[0]
+		}
+	}
+
+	stats := computeStats(history, m.GuildID, since, now)
+
+	embed := &discordgo.MessageEmbed{
+		Title: fmt.Sprintf("📈 Mute statistics (%s)", periodName),
+		Color: 0x9b59b6,
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Votes", Value: fmt.Sprintf("%d", stats.Votes), Inline: true},
+			{Name: "Mutes", Value: fmt.Sprintf("%d", stats.Mutes), Inline: true},
+			{Name: "Cleans", Value: fmt.Sprintf("%d", stats.Cleans), Inline: true},
+			{
+				Name: "Vote campaigns",
+				Value: fmt.Sprintf("%d reached the threshold, %d expired (%.0f%% success)",
+					stats.CampaignsReached, stats.CampaignsExpired, stats.SuccessRate()),
+			},
+			{Name: "Most muted", Value: formatStatsEntries(stats.MostMuted, "mutes"), Inline: true},
+			{Name: "Most active voters", Value: formatStatsEntries(stats.MostActive, "votes"), Inline: true},
+		},
+	}
+	if stats.CampaignsReached > 0 {
+		embed.Fields[3].Value += fmt.Sprintf("\nAverage time to reach the threshold: %s",
+			stats.AverageTimeToThreshold.Round(time.Second))
+	}
+
+	_, err := s.ChannelMessageSendEmbed(m.ChannelID, embed)
+	if err != nil {
+		slog.Error("Error sending stats", "guild_id", m.GuildID, "error", err)
+	}
+}
+
+func formatStatsEntries(entries []StatsEntry, unit string) string {
+	if len(entries) == 0 {
+		return "-"
+	}
+
+	var lines []string
+	for i, entry := range entries {
+		lines = append(lines, fmt.Sprintf("%d. %s (%d %s)", i+1, entry.Username, entry.Count, unit))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/tu-usuario/DiscMuteBot/audit"
+)
+
+// runSubcommand runs a maintenance command of the bot binary and returns the exit code
+func runSubcommand(name string, args []string) int {
+	switch name {
+	case "verify-log":
+		return verifyLog(args)
+	case "rebuild-state":
+		return rebuildState(args)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\nUsage:\n  DiscMuteBot                     start the bot\n"+
+			"  DiscMuteBot verify-log [dir]    verify the hash chain of the audit log (logs by default)\n"+
+			"  DiscMuteBot rebuild-state       rebuild mute_data.json from the audit log (-dir, -output, -tz)\n", name)
+		return 2
+	}
+}
+
+// verifyLog checks the hash chain of the daily log files and reports the first broken link
+func verifyLog(args []string) int {
+	dir := "logs"
+	if len(args) > 0 {
+		dir = args[0]
+	}
+
+	reports, err := audit.Verify(dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error verifying %s: %v\n", dir, err)
+		return 1
+	}
+
+	code := 0
+	for _, report := range reports {
+		if report.Files == 0 {
+			continue
+		}
+
+		fmt.Printf("%s files: %d, records: %d", report.Extension, report.Files, report.Records)
+		if report.Unchained > 0 {
+			fmt.Printf(" (%d written before the hash chain)", report.Unchained)
+		}
+		fmt.Println()
+
+		if report.Broken == nil {
+			fmt.Println("  ✅ Chain intact")
+			continue
+		}
+
+		code = 1
+		fmt.Printf("  ❌ Broken link at %s:%d\n", report.Broken.File, report.Broken.Line)
+		fmt.Printf("     expected previous hash %s\n", report.Broken.Expected)
+		fmt.Printf("     found %s\n", report.Broken.Found)
+		fmt.Println("     The previous record was modified or records were removed before this line")
+	}
+	return code
+}
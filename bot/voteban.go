@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/tu-usuario/DiscMuteBot/audit"
+)
+
+// Maximum duration of a temporary vote ban
+const VOTE_BAN_MAX_MINUTES = 365 * 24 * 60
+
+type VoteBan struct {
+	UserID    string    `json:"user_id"`
+	Username  string    `json:"username"`
+	BannedBy  string    `json:"banned_by"`
+	CreatedAt time.Time `json:"created_at"`
+	// Zero for permanent bans
+	Until time.Time `json:"until,omitempty"`
+}
+
+// activeVoteBan returns the ban of the user in the guild, removing it if it expired
+func activeVoteBan(guildID, userID string) (VoteBan, bool) {
+	ban, exists := muteData.VoteBans[guildID][userID]
+	if !exists {
+		return VoteBan{}, false
+	}
+
+	if !ban.Until.IsZero() && clock.Now().After(ban.Until) {
+		delete(muteData.VoteBans[guildID], userID)
+		saveMuteData()
+		return VoteBan{}, false
+	}
+	return ban, true
+}
+
+// handleVoteBan processes !voteban @user [duration]
+func handleVoteBan(s Discord, m *discordgo.MessageCreate, target *discordgo.User) {
+	if target.Bot {
+		s.ChannelMessageSend(m.ChannelID, "⚠️ Bots can't vote.")
+		return
+	}
+
+	ban := VoteBan{
+		UserID:    target.ID,
+		Username:  target.Username,
+		BannedBy:  m.Author.Username,
+		CreatedAt: clock.Now(),
+	}
+
+	args := commandArgs(m.Content)
+	if len(args) > 0 {
+		minutes, err := parseMinutes(args[0], 1, VOTE_BAN_MAX_MINUTES)
+		if err != nil {
+			s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("❌ Invalid duration: %v", err))
+			return
+		}
+		ban.Until = ban.CreatedAt.Add(time.Duration(minutes) * time.Minute)
+	}
+
+	if muteData.VoteBans[m.GuildID] == nil {
+		muteData.VoteBans[m.GuildID] = make(map[string]VoteBan)
+	}
+	muteData.VoteBans[m.GuildID][target.ID] = ban
+
+	// Drop the votes the user already cast in this guild
+	dropped := 0
+	for userID, muteInfo := range muteData.MutedUsers {
+		if muteInfo.GuildID != "" && muteInfo.GuildID != m.GuildID {
+			continue
+		}
+		if _, voted := muteInfo.MutedBy[target.ID]; voted {
+			delete(muteInfo.MutedBy, target.ID)
+			muteData.MutedUsers[userID] = muteInfo
+			dropped++
+		}
+	}
+	saveMuteData()
+
+	recordEvent(audit.Event{
+		Type:        "VOTEBAN",
+		GuildID:     m.GuildID,
+		ChannelID:   m.ChannelID,
+		MessageID:   m.ID,
+		InitiatorID: m.Author.ID,
+		Initiator:   m.Author.Username,
+		TargetID:    target.ID,
+		Target:      target.Username,
+		Votes:       dropped,
+		ExpiresAt:   ban.Until,
+	})
+
+	msg := fmt.Sprintf("🚫 %s has been banned from voting permanently.", target.Username)
+	if !ban.Until.IsZero() {
+		msg = fmt.Sprintf("🚫 %s has been banned from voting for %s.", target.Username, timeUntil(ban.Until).Round(time.Minute))
+	}
+	if dropped > 0 {
+		msg += fmt.Sprintf(" %d active votes have been removed.", dropped)
+	}
+	s.ChannelMessageSend(m.ChannelID, msg)
+}
+
+// handleVoteUnban processes !voteunban @user
+func handleVoteUnban(s Discord, m *discordgo.MessageCreate, target *discordgo.User) {
+	if _, banned := activeVoteBan(m.GuildID, target.ID); !banned {
+		s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("⚠️ %s is not banned from voting.", target.Username))
+		return
+	}
+
+	delete(muteData.VoteBans[m.GuildID], target.ID)
+	saveMuteData()
+
+	recordEvent(audit.Event{
+		Type:        "VOTEUNBAN",
+		GuildID:     m.GuildID,
+		ChannelID:   m.ChannelID,
+		MessageID:   m.ID,
+		InitiatorID: m.Author.ID,
+		Initiator:   m.Author.Username,
+		TargetID:    target.ID,
+		Target:      target.Username,
+	})
+
+	s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("✅ %s can vote again.", target.Username))
+}
+
+// handleVoteBanList processes !votebans
+func handleVoteBanList(s Discord, m *discordgo.MessageCreate) {
+	var bans []VoteBan
+	for userID := range muteData.VoteBans[m.GuildID] {
+		if ban, banned := activeVoteBan(m.GuildID, userID); banned {
+			bans = append(bans, ban)
+		}
+	}
+
+	if len(bans) == 0 {
+		s.ChannelMessageSend(m.ChannelID, "🚫 No users are banned from voting.")
+		return
+	}
+
+	sort.Slice(bans, func(a, b int) bool {
+		return bans[a].CreatedAt.Before(bans[b].CreatedAt)
+	})
+
+	var msg strings.Builder
+	msg.WriteString("🚫 **Users banned from voting:**\n\n")
+	for _, ban := range bans {
+		until := "permanent"
+		if !ban.Until.IsZero() {
+			until = "ends in " + timeUntil(ban.Until).Round(time.Minute).String()
+		}
+		msg.WriteString(fmt.Sprintf("**%s** - %s (by %s on %s)\n", ban.Username, until, ban.BannedBy,
+			ban.CreatedAt.Format("2006-01-02 15:04")))
+	}
+
+	s.ChannelMessageSend(m.ChannelID, msg.String())
+}
@@ -0,0 +1,262 @@
+// Package e2e runs the bot binary against the fake Discord of the fakediscord package.
+// The tests build the bot, so they are skipped with -short.
+package e2e
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/tu-usuario/DiscMuteBot/audit"
+	"github.com/tu-usuario/DiscMuteBot/fakediscord"
+)
+
+const timeout = 10 * time.Second
+
+// Binary of the bot, built once by TestMain
+var botBinary string
+
+func TestMain(m *testing.M) {
+	code := func() int {
+		dir, err := os.MkdirTemp("", "discmutebot-e2e")
+		if err != nil {
+			panic(err)
+		}
+		defer os.RemoveAll(dir)
+
+		botBinary = filepath.Join(dir, "DiscMuteBot")
+		build := exec.Command("go", "build", "-o", botBinary, "github.com/tu-usuario/DiscMuteBot/bot")
+		if out, err := build.CombinedOutput(); err != nil {
+			os.Stderr.Write(out)
+			panic(err)
+		}
+		return m.Run()
+	}()
+	os.Exit(code)
+}
+
+// bot is a running bot process connected to a fake Discord
+type bot struct {
+	t       *testing.T
+	discord *fakediscord.Server
+	dir     string
+	cmd     *exec.Cmd
+	output  *lockedBuffer
+}
+
+type lockedBuffer struct {
+	mu sync.Mutex
+	bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.Buffer.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.Buffer.String()
+}
+
+// startBot starts the bot in a temporary directory and waits until it's ready
+func startBot(t *testing.T, discord *fakediscord.Server) *bot {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("end-to-end test")
+	}
+
+	dir := t.TempDir()
+	config, err := json.Marshal(map[string]interface{}{
+		"token":                    "fake-token",
+		"discord_url":              discord.URL,
+		"audit_sinks":              []string{"jsonl"},
+		"shutdown_timeout_seconds": 5,
+		"log_level":                "debug",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), config, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	b := &bot{t: t, discord: discord, dir: dir, output: &lockedBuffer{}}
+	b.cmd = exec.Command(botBinary)
+	b.cmd.Dir = dir
+	b.cmd.Stdout = b.output
+	b.cmd.Stderr = b.output
+	if err := b.cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if b.cmd.ProcessState == nil {
+			b.cmd.Process.Kill()
+			b.cmd.Wait()
+		}
+		if t.Failed() {
+			t.Logf("bot output:\n%s", b.output.String())
+		}
+	})
+
+	if err := discord.WaitReady(timeout); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// say sends the message and waits for an answer of the bot in the text channel that contains the text
+func (b *bot) say(author *discordgo.User, content, answer string) {
+	b.t.Helper()
+	sent := len(b.discord.Messages(fakediscord.TextChannelID))
+	b.discord.SendMessage(author, content)
+
+	var last string
+	found := b.discord.Wait(timeout, func() bool {
+		messages := b.discord.Messages(fakediscord.TextChannelID)
+		for _, msg := range messages[sent:] {
+			if msg.Author.ID == fakediscord.BotID {
+				last = msg.Content
+				if strings.Contains(msg.Content, answer) {
+					return true
+				}
+			}
+		}
+		return false
+	})
+	if !found {
+		b.t.Fatalf("%q: no answer with %q, last answer %q", content, answer, last)
+	}
+}
+
+// stop sends SIGTERM to the bot and waits for it to exit
+func (b *bot) stop() {
+	b.t.Helper()
+	if err := b.cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		b.t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- b.cmd.Wait() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			b.t.Fatalf("bot exited with %v", err)
+		}
+	case <-time.After(timeout):
+		b.t.Fatal("bot still running after SIGTERM")
+	}
+}
+
+// events returns the types of the events of the user in history.jsonl
+func (b *bot) events(userID string) []string {
+	b.t.Helper()
+	file, err := os.Open(filepath.Join(b.dir, "history.jsonl"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		b.t.Fatal(err)
+	}
+	defer file.Close()
+
+	var types []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event audit.Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			b.t.Fatal(err)
+		}
+		if event.TargetID == userID {
+			types = append(types, event.Type)
+		}
+	}
+	return types
+}
+
+func mention(user *discordgo.User) string {
+	return "<@" + user.ID + ">"
+}
+
+func TestVoteMuteAndPardon(t *testing.T) {
+	discord := fakediscord.NewServer()
+	defer discord.Close()
+	owner := &discordgo.User{ID: fakediscord.OwnerID, Username: "owner"}
+	alice := discord.AddMember("alice")
+	carol := discord.AddMember("carol")
+	target := discord.AddMember("bob")
+	moderators := discord.AddRole("Moderators", discordgo.PermissionVoiceMuteMembers)
+	moderator := discord.AddMember("mod", moderators.ID)
+
+	b := startBot(t, discord)
+	b.say(owner, "!ping", "Pong!")
+	b.say(owner, "!config set votes_needed 2", "votes_needed")
+	b.say(owner, "!config set sanction_mode timeout", "sanction_mode")
+
+	discord.JoinVoice(target.ID)
+	b.say(alice, "!mute "+mention(target), "Current votes: 1/2")
+	b.say(alice, "!mute "+mention(target), "already voted")
+	b.say(carol, "!mute "+mention(target), "has been muted")
+	if !discord.Muted(target.ID) || !discord.TimedOut(target.ID) {
+		t.Fatal("bob not muted and timed out in Discord")
+	}
+
+	b.say(alice, "!forceunmute "+mention(target), "don't have permission")
+	b.say(moderator, "!forceunmute "+mention(target), "has been unmuted")
+	if discord.Muted(target.ID) || discord.TimedOut(target.ID) {
+		t.Fatal("bob still muted or timed out in Discord")
+	}
+
+	b.stop()
+	want := []string{"VOTE", "VOTE", "MUTE", "FORCE_UNMUTE"}
+	if events := b.events(target.ID); strings.Join(events, " ") != strings.Join(want, " ") {
+		t.Errorf("events = %v, want %v", events, want)
+	}
+	if _, err := os.Stat(filepath.Join(b.dir, "mute_data.json")); err != nil {
+		t.Errorf("mute data not saved: %v", err)
+	}
+}
+
+func TestMuteFollowsVoiceChannels(t *testing.T) {
+	discord := fakediscord.NewServer()
+	defer discord.Close()
+	moderators := discord.AddRole("Moderators", discordgo.PermissionVoiceMuteMembers)
+	moderator := discord.AddMember("mod", moderators.ID)
+	target := discord.AddMember("bob")
+
+	b := startBot(t, discord)
+	discord.JoinVoice(target.ID)
+	b.say(moderator, "!forcemute "+mention(target)+" 30m spamming", "has been muted")
+
+	// A moderator lifts the mute by hand, the bot doesn't fight them
+	if err := discord.SetMute(target.ID, false); err != nil {
+		t.Fatal(err)
+	}
+	overridden := discord.Wait(timeout, func() bool {
+		events := b.events(target.ID)
+		return len(events) > 0 && events[len(events)-1] == "MANUAL_OVERRIDE"
+	})
+	if !overridden {
+		t.Fatalf("no MANUAL_OVERRIDE, events %v", b.events(target.ID))
+	}
+
+	discord.LeaveVoice(target.ID)
+	discord.JoinVoice(target.ID)
+	time.Sleep(200 * time.Millisecond)
+	if discord.Muted(target.ID) {
+		t.Error("muted again after the override")
+	}
+	b.stop()
+}
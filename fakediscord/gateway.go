@@ -0,0 +1,115 @@
+package fakediscord
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/gorilla/websocket"
+)
+
+// Opcodes of the gateway
+const (
+	opDispatch     = 0
+	opHeartbeat    = 1
+	opIdentify     = 2
+	opResume       = 6
+	opHello        = 10
+	opHeartbeatAck = 11
+)
+
+type payload struct {
+	Op       int             `json:"op"`
+	Sequence int64           `json:"s,omitempty"`
+	Type     string          `json:"t,omitempty"`
+	Data     json.RawMessage `json:"d"`
+}
+
+var upgrader = websocket.Upgrader{}
+
+// handleGateway talks to the bot: Hello, Identify, Ready and Guild Create, then the heartbeats
+func (s *Server) handleGateway(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		slog.Error("Error upgrading the gateway connection", "error", err)
+		return
+	}
+	defer conn.Close()
+
+	if err := s.send(conn, opHello, "", map[string]int{"heartbeat_interval": 41250}); err != nil {
+		return
+	}
+
+	for {
+		var p payload
+		if err := conn.ReadJSON(&p); err != nil {
+			s.mu.Lock()
+			if s.conn == conn {
+				s.conn = nil
+			}
+			s.mu.Unlock()
+			return
+		}
+
+		switch p.Op {
+		case opHeartbeat:
+			s.send(conn, opHeartbeatAck, "", nil)
+		case opIdentify, opResume:
+			s.identify(conn)
+		}
+	}
+}
+
+// identify sends the Ready event and the guild to the bot, and dispatches the next events to it
+func (s *Server) identify(conn *websocket.Conn) {
+	s.mu.Lock()
+	ready := &discordgo.Ready{
+		Version:   9,
+		SessionID: "fake-session",
+		User:      s.users[BotID],
+		Guilds:    []*discordgo.Guild{{ID: GuildID, Unavailable: true}},
+	}
+	guild, err := json.Marshal(s.guild)
+	s.mu.Unlock()
+	if err != nil {
+		slog.Error("Error serializing the guild", "error", err)
+		return
+	}
+
+	s.send(conn, opDispatch, "READY", ready)
+	s.send(conn, opDispatch, "GUILD_CREATE", json.RawMessage(guild))
+
+	s.mu.Lock()
+	s.conn = conn
+	s.mu.Unlock()
+}
+
+// dispatch sends the event to the bot, if it's connected
+func (s *Server) dispatch(event string, data interface{}) {
+	s.mu.Lock()
+	conn := s.conn
+	s.mu.Unlock()
+	if conn == nil {
+		return
+	}
+	if err := s.send(conn, opDispatch, event, data); err != nil {
+		slog.Error("Error dispatching gateway event", "event", event, "error", err)
+	}
+}
+
+func (s *Server) send(conn *websocket.Conn, op int, event string, data interface{}) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := payload{Op: op, Type: event, Data: raw}
+	if op == opDispatch {
+		s.sequence++
+		p.Sequence = s.sequence
+	}
+	return conn.WriteJSON(p)
+}
@@ -0,0 +1,256 @@
+package fakediscord
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// handleAPI routes the REST requests of the bot, /api/v9/<resource>/...
+func (s *Server) handleAPI(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v9/"), "/"), "/")
+	route := r.Method + " " + parts[0]
+	if len(parts) > 2 {
+		route += " " + parts[2]
+	}
+	slog.Debug("Fake Discord request", "method", r.Method, "path", r.URL.Path)
+
+	switch {
+	case r.Method == http.MethodGet && parts[0] == "gateway":
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"url":    "ws" + strings.TrimPrefix(s.URL, "http") + "/gateway",
+			"shards": 1,
+		})
+	case route == "POST applications commands" || route == "POST applications guilds":
+		s.createCommand(w, r)
+	case route == "POST channels messages":
+		s.createMessage(w, r, parts[1])
+	case route == "PATCH channels messages" && len(parts) == 4:
+		s.editMessage(w, r, parts[1], parts[3])
+	case route == "GET channels" && len(parts) == 2:
+		s.getChannel(w, parts[1])
+	case route == "GET guilds" && len(parts) == 2:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		writeJSON(w, http.StatusOK, s.guild)
+	case route == "GET guilds members" && len(parts) == 4:
+		s.getMember(w, parts[3])
+	case route == "PATCH guilds members" && len(parts) == 4:
+		s.updateMember(w, r, parts[3])
+	case route == "GET guilds audit-logs":
+		writeJSON(w, http.StatusOK, &discordgo.GuildAuditLog{})
+	case route == "GET users" && len(parts) == 2:
+		s.getUser(w, parts[1])
+	case route == "POST users channels":
+		s.createDM(w, r)
+	case r.Method == http.MethodPost && parts[0] == "interactions":
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, &APIError{Status: http.StatusNotFound, Message: "404: Not Found"})
+	}
+}
+
+func (s *Server) createCommand(w http.ResponseWriter, r *http.Request) {
+	var command discordgo.ApplicationCommand
+	if err := json.NewDecoder(r.Body).Decode(&command); err != nil {
+		writeError(w, &APIError{Status: http.StatusBadRequest, Code: 50035, Message: "Invalid Form Body"})
+		return
+	}
+
+	s.mu.Lock()
+	command.ID = s.newID()
+	s.commands = append(s.commands, &command)
+	select {
+	case <-s.ready:
+	default:
+		close(s.ready)
+	}
+	s.mu.Unlock()
+	writeJSON(w, http.StatusCreated, &command)
+}
+
+func (s *Server) createMessage(w http.ResponseWriter, r *http.Request, channelID string) {
+	var data discordgo.MessageSend
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		writeError(w, &APIError{Status: http.StatusBadRequest, Code: 50035, Message: "Invalid Form Body"})
+		return
+	}
+	if data.Content == "" && len(data.Embeds) == 0 && data.Embed == nil {
+		writeError(w, &APIError{Status: http.StatusBadRequest, Code: discordgo.ErrCodeCannotSendEmptyMessage,
+			Message: "Cannot send an empty message"})
+		return
+	}
+
+	s.mu.Lock()
+	msg := &discordgo.Message{
+		ID:         s.newID(),
+		ChannelID:  channelID,
+		Author:     s.users[BotID],
+		Content:    data.Content,
+		Embeds:     data.Embeds,
+		Components: data.Components,
+		Timestamp:  time.Now(),
+	}
+	if data.Embed != nil {
+		msg.Embeds = append(msg.Embeds, data.Embed)
+	}
+	if channelID == TextChannelID || channelID == VoiceChannelID {
+		msg.GuildID = GuildID
+	}
+	s.messages = append(s.messages, msg)
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, msg)
+}
+
+func (s *Server) editMessage(w http.ResponseWriter, r *http.Request, channelID, messageID string) {
+	var data discordgo.MessageEdit
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		writeError(w, &APIError{Status: http.StatusBadRequest, Code: 50035, Message: "Invalid Form Body"})
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, msg := range s.messages {
+		if msg.ID != messageID || msg.ChannelID != channelID {
+			continue
+		}
+		if data.Content != nil {
+			msg.Content = *data.Content
+		}
+		if data.Embeds != nil {
+			msg.Embeds = data.Embeds
+		}
+		if data.Components != nil {
+			msg.Components = data.Components
+		}
+		writeJSON(w, http.StatusOK, msg)
+		return
+	}
+	writeError(w, &APIError{Status: http.StatusNotFound, Code: discordgo.ErrCodeUnknownMessage, Message: "Unknown Message"})
+}
+
+func (s *Server) getChannel(w http.ResponseWriter, channelID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, channel := range s.guild.Channels {
+		if channel.ID == channelID {
+			writeJSON(w, http.StatusOK, channel)
+			return
+		}
+	}
+	writeError(w, &APIError{Status: http.StatusNotFound, Code: discordgo.ErrCodeUnknownChannel, Message: "Unknown Channel"})
+}
+
+func (s *Server) getMember(w http.ResponseWriter, userID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	member := s.member(userID)
+	if member == nil {
+		writeError(w, &APIError{Status: http.StatusNotFound, Code: discordgo.ErrCodeUnknownMember, Message: "Unknown Member"})
+		return
+	}
+	writeJSON(w, http.StatusOK, member)
+}
+
+func (s *Server) updateMember(w http.ResponseWriter, r *http.Request, userID string) {
+	var data struct {
+		Mute *bool `json:"mute"`
+		// Absent when the timeout doesn't change, null to remove it
+		Timeout json.RawMessage `json:"communication_disabled_until"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		writeError(w, &APIError{Status: http.StatusBadRequest, Code: 50035, Message: "Invalid Form Body"})
+		return
+	}
+
+	s.mu.Lock()
+	exists := s.member(userID) != nil
+	s.mu.Unlock()
+	if !exists {
+		writeError(w, &APIError{Status: http.StatusNotFound, Code: discordgo.ErrCodeUnknownMember, Message: "Unknown Member"})
+		return
+	}
+
+	if data.Mute != nil {
+		if err := s.setMute(userID, *data.Mute); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+	if data.Timeout != nil {
+		var until *time.Time
+		if err := json.Unmarshal(data.Timeout, &until); err != nil {
+			writeError(w, &APIError{Status: http.StatusBadRequest, Code: 50035, Message: "Invalid Form Body"})
+			return
+		}
+		s.mu.Lock()
+		s.member(userID).CommunicationDisabledUntil = until
+		s.mu.Unlock()
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) getUser(w http.ResponseWriter, userID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if userID == "@me" {
+		userID = BotID
+	}
+	user, exists := s.users[userID]
+	if !exists {
+		writeError(w, &APIError{Status: http.StatusNotFound, Code: discordgo.ErrCodeUnknownUser, Message: "Unknown User"})
+		return
+	}
+	writeJSON(w, http.StatusOK, user)
+}
+
+// createDM opens a DM channel with the user, its messages are kept like the ones of the guild
+func (s *Server) createDM(w http.ResponseWriter, r *http.Request) {
+	var data struct {
+		RecipientID string `json:"recipient_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		writeError(w, &APIError{Status: http.StatusBadRequest, Code: 50035, Message: "Invalid Form Body"})
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, exists := s.users[data.RecipientID]
+	if !exists {
+		writeError(w, &APIError{Status: http.StatusNotFound, Code: discordgo.ErrCodeUnknownUser, Message: "Unknown User"})
+		return
+	}
+	writeJSON(w, http.StatusOK, &discordgo.Channel{
+		ID:         DMChannelID(user.ID),
+		Type:       discordgo.ChannelTypeDM,
+		Recipients: []*discordgo.User{user},
+	})
+}
+
+// DMChannelID returns the channel of the DMs between the bot and the user
+func DMChannelID(userID string) string {
+	return "dm" + userID
+}
+
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		slog.Error("Error writing fake Discord answer", "error", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		apiErr = &APIError{Status: http.StatusInternalServerError, Message: err.Error()}
+	}
+	writeJSON(w, apiErr.Status, map[string]interface{}{"code": apiErr.Code, "message": apiErr.Message})
+}
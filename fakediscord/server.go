@@ -0,0 +1,293 @@
+// Package fakediscord is a local stand-in for Discord, to test the bot end to end without network.
+// It implements the REST endpoints and gateway events the bot uses for one guild, with a text
+// and a voice channel. The tests script the members, their messages and voice states, and check
+// the messages and mutes of the bot.
+package fakediscord
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/gorilla/websocket"
+)
+
+// IDs of the fake guild
+const (
+	GuildID        = "100"
+	TextChannelID  = "101"
+	VoiceChannelID = "102"
+	BotID          = "200"
+	OwnerID        = "201"
+)
+
+// Permissions of the @everyone role
+const everyonePermissions = discordgo.PermissionViewChannel | discordgo.PermissionSendMessages |
+	discordgo.PermissionReadMessageHistory | discordgo.PermissionVoiceConnect | discordgo.PermissionVoiceSpeak
+
+var mention = regexp.MustCompile(`<@!?(\d+)>`)
+
+// Server is the fake Discord, serving the REST API in /api/v9/ and the gateway in /gateway
+type Server struct {
+	// Base URL for the discord_url of config.json
+	URL string
+
+	http *httptest.Server
+
+	mu       sync.Mutex
+	nextID   int
+	guild    *discordgo.Guild
+	users    map[string]*discordgo.User
+	messages []*discordgo.Message
+	commands []*discordgo.ApplicationCommand
+	// Gateway connection of the bot, nil until it identifies
+	conn     *websocket.Conn
+	sequence int64
+	// Closed when the bot registers its application commands, the last step of its Ready handler
+	ready chan struct{}
+}
+
+// NewServer starts a fake Discord with a guild owned by the user "owner"
+func NewServer() *Server {
+	s := &Server{
+		nextID: 1000,
+		users:  make(map[string]*discordgo.User),
+		ready:  make(chan struct{}),
+	}
+	s.guild = &discordgo.Guild{
+		ID:      GuildID,
+		Name:    "Fake server",
+		OwnerID: OwnerID,
+		Roles: []*discordgo.Role{
+			{ID: GuildID, Name: "@everyone", Permissions: everyonePermissions},
+		},
+		Channels: []*discordgo.Channel{
+			{ID: TextChannelID, GuildID: GuildID, Name: "general", Type: discordgo.ChannelTypeGuildText},
+			{ID: VoiceChannelID, GuildID: GuildID, Name: "Voice", Type: discordgo.ChannelTypeGuildVoice},
+		},
+	}
+	s.addMember(&discordgo.User{ID: BotID, Username: "DiscMuteBot", Bot: true})
+	s.addMember(&discordgo.User{ID: OwnerID, Username: "owner"})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/gateway", s.handleGateway)
+	mux.HandleFunc("/gateway/", s.handleGateway)
+	mux.HandleFunc("/api/v9/", s.handleAPI)
+	s.http = httptest.NewServer(mux)
+	s.URL = s.http.URL
+	return s
+}
+
+// Close stops the server and disconnects the bot
+func (s *Server) Close() {
+	s.mu.Lock()
+	if s.conn != nil {
+		s.conn.Close()
+	}
+	s.mu.Unlock()
+	s.http.Close()
+}
+
+func (s *Server) newID() string {
+	s.nextID++
+	return strconv.Itoa(s.nextID)
+}
+
+func (s *Server) addMember(user *discordgo.User, roles ...string) *discordgo.Member {
+	member := &discordgo.Member{GuildID: GuildID, User: user, Roles: roles, JoinedAt: time.Now()}
+	s.users[user.ID] = user
+	s.guild.Members = append(s.guild.Members, member)
+	s.guild.MemberCount++
+	return member
+}
+
+// AddMember adds a member to the guild, with a new ID, and announces them to the bot if it's connected
+func (s *Server) AddMember(username string, roles ...string) *discordgo.User {
+	s.mu.Lock()
+	user := &discordgo.User{ID: s.newID(), Username: username}
+	member := s.addMember(user, roles...)
+	s.mu.Unlock()
+
+	s.dispatch("GUILD_MEMBER_ADD", member)
+	return user
+}
+
+// AddRole creates a role with the permissions
+func (s *Server) AddRole(name string, permissions int64) *discordgo.Role {
+	s.mu.Lock()
+	role := &discordgo.Role{ID: s.newID(), Name: name, Permissions: permissions, Position: len(s.guild.Roles)}
+	s.guild.Roles = append(s.guild.Roles, role)
+	s.mu.Unlock()
+
+	s.dispatch("GUILD_ROLE_CREATE", &discordgo.GuildRole{GuildID: GuildID, Role: role})
+	return role
+}
+
+func (s *Server) member(userID string) *discordgo.Member {
+	for _, member := range s.guild.Members {
+		if member.User.ID == userID {
+			return member
+		}
+	}
+	return nil
+}
+
+func (s *Server) voiceState(userID string) *discordgo.VoiceState {
+	for _, vs := range s.guild.VoiceStates {
+		if vs.UserID == userID {
+			return vs
+		}
+	}
+	return nil
+}
+
+// SendMessage posts a message of the user in the text channel. Mentions like <@ID> are resolved.
+func (s *Server) SendMessage(author *discordgo.User, content string) *discordgo.Message {
+	s.mu.Lock()
+	msg := &discordgo.Message{
+		ID:        s.newID(),
+		ChannelID: TextChannelID,
+		GuildID:   GuildID,
+		Author:    author,
+		Content:   content,
+		Timestamp: time.Now(),
+		Member:    &discordgo.Member{Roles: s.member(author.ID).Roles},
+	}
+	for _, match := range mention.FindAllStringSubmatch(content, -1) {
+		if user, exists := s.users[match[1]]; exists {
+			msg.Mentions = append(msg.Mentions, user)
+		}
+	}
+	s.messages = append(s.messages, msg)
+	s.mu.Unlock()
+
+	s.dispatch("MESSAGE_CREATE", msg)
+	return msg
+}
+
+// JoinVoice moves the member to the voice channel, keeping their server mute
+func (s *Server) JoinVoice(userID string) {
+	s.mu.Lock()
+	vs := s.voiceState(userID)
+	if vs == nil {
+		vs = &discordgo.VoiceState{GuildID: GuildID, UserID: userID, SessionID: s.newID(), Mute: s.member(userID).Mute}
+		s.guild.VoiceStates = append(s.guild.VoiceStates, vs)
+	}
+	vs.ChannelID = VoiceChannelID
+	update := *vs
+	s.mu.Unlock()
+
+	s.dispatch("VOICE_STATE_UPDATE", &update)
+}
+
+// LeaveVoice disconnects the member from voice
+func (s *Server) LeaveVoice(userID string) {
+	s.mu.Lock()
+	vs := s.voiceState(userID)
+	if vs == nil {
+		s.mu.Unlock()
+		return
+	}
+	for i, other := range s.guild.VoiceStates {
+		if other == vs {
+			s.guild.VoiceStates = append(s.guild.VoiceStates[:i], s.guild.VoiceStates[i+1:]...)
+			break
+		}
+	}
+	update := *vs
+	update.ChannelID = ""
+	s.mu.Unlock()
+
+	s.dispatch("VOICE_STATE_UPDATE", &update)
+}
+
+// SetMute changes the server mute of the member by hand, like a moderator in the Discord client
+func (s *Server) SetMute(userID string, mute bool) error {
+	return s.setMute(userID, mute)
+}
+
+func (s *Server) setMute(userID string, mute bool) error {
+	s.mu.Lock()
+	vs := s.voiceState(userID)
+	if vs == nil {
+		s.mu.Unlock()
+		return &APIError{Status: http.StatusBadRequest, Code: discordgo.ErrCodeTargetIsNotConnectedToVoice,
+			Message: "Target user is not connected to voice."}
+	}
+	s.member(userID).Mute = mute
+	vs.Mute = mute
+	update := *vs
+	s.mu.Unlock()
+
+	s.dispatch("VOICE_STATE_UPDATE", &update)
+	return nil
+}
+
+// Muted tells whether the member has a server mute
+func (s *Server) Muted(userID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	member := s.member(userID)
+	return member != nil && member.Mute
+}
+
+// TimedOut tells whether the member has a timeout
+func (s *Server) TimedOut(userID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	member := s.member(userID)
+	return member != nil && member.CommunicationDisabledUntil != nil
+}
+
+// Messages returns the messages sent to the channel, by the members and the bot
+func (s *Server) Messages(channelID string) []*discordgo.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var messages []*discordgo.Message
+	for _, msg := range s.messages {
+		if msg.ChannelID == channelID {
+			messages = append(messages, msg)
+		}
+	}
+	return messages
+}
+
+// WaitReady waits until the bot registers its application commands after the Ready event
+func (s *Server) WaitReady(timeout time.Duration) error {
+	select {
+	case <-s.ready:
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("the bot wasn't ready after %s", timeout)
+	}
+}
+
+// Wait polls the condition until it's true or the timeout expires
+func (s *Server) Wait(timeout time.Duration, condition func() bool) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		if condition() {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// APIError is an error answer of the REST API
+type APIError struct {
+	Status  int
+	Code    int
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("HTTP %d: %s (code %d)", e.Status, e.Message, e.Code)
+}
@@ -2,10 +2,12 @@ module github.com/tu-usuario/DiscMuteBot
 
 go 1.22
 
-require github.com/bwmarrin/discordgo v0.27.1
+require (
+	github.com/bwmarrin/discordgo v0.27.1
+	github.com/gorilla/websocket v1.5.0
+)
 
 require (
-	github.com/gorilla/websocket v1.5.0 // indirect
 	golang.org/x/crypto v0.12.0 // indirect
 	golang.org/x/sys v0.11.0 // indirect
 )
@@ -0,0 +1,263 @@
+// Package metrics keeps counters, gauges and histograms and serves them
+// in the Prometheus text exposition format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Registry holds the metrics served by its handler
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+}
+
+type metric interface {
+	write(w io.Writer)
+}
+
+// Sample is a value of a metric computed when it's scraped
+type Sample struct {
+	Labels []string
+	Value  float64
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) register(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics = append(r.metrics, m)
+}
+
+// Export writes every metric in the text exposition format
+func (r *Registry) Export(w io.Writer) {
+	r.mu.Lock()
+	metrics := append([]metric{}, r.metrics...)
+	r.mu.Unlock()
+
+	for _, m := range metrics {
+		m.write(w)
+	}
+}
+
+// Handler serves the metrics for Prometheus
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.Export(w)
+	})
+}
+
+// desc is the name, help and label names of a metric
+type desc struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+}
+
+func (d desc) writeHeader(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.name, d.help, d.name, d.kind)
+}
+
+// series returns the name of a series with its labels, like name{guild="1"}
+func (d desc) series(suffix string, values []string, extra ...string) string {
+	var pairs []string
+	for i, label := range d.labels {
+		pairs = append(pairs, label+`="`+escape(values[i])+`"`)
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+`="`+escape(extra[i+1])+`"`)
+	}
+	if len(pairs) == 0 {
+		return d.name + suffix
+	}
+	return d.name + suffix + "{" + strings.Join(pairs, ",") + "}"
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escape escapes the backslashes, quotes and line breaks of a label value
+func escape(value string) string {
+	return labelEscaper.Replace(strings.ToValidUTF8(value, "\uFFFD"))
+}
+
+func key(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// vec keeps a value for each combination of label values
+type vec struct {
+	desc
+	mu     sync.Mutex
+	values map[string]float64
+	labels map[string][]string
+}
+
+func newVec(d desc) *vec {
+	v := &vec{desc: d, values: make(map[string]float64), labels: make(map[string][]string)}
+	// Metrics without labels are exported from the start
+	if len(d.labels) == 0 {
+		v.add(0, nil)
+	}
+	return v
+}
+
+func (v *vec) add(delta float64, labels []string) {
+	if len(labels) != len(v.desc.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d labels, got %d", v.name, len(v.desc.labels), len(labels)))
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	k := key(labels)
+	v.values[k] += delta
+	v.labels[k] = append([]string{}, labels...)
+}
+
+func (v *vec) write(w io.Writer) {
+	v.writeHeader(w)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	keys := make([]string, 0, len(v.values))
+	for k := range v.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s %s\n", v.series("", v.labels[k]), formatValue(v.values[k]))
+	}
+}
+
+// Counter is a value that only goes up, with optional labels
+type Counter struct {
+	*vec
+}
+
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{newVec(desc{name: name, help: help, kind: "counter", labels: labels})}
+	r.register(c)
+	return c
+}
+
+// Inc adds one to the series of the label values
+func (c *Counter) Inc(labels ...string) {
+	c.add(1, labels)
+}
+
+// GaugeFunc is a gauge computed each time the metrics are scraped
+type GaugeFunc struct {
+	desc
+	collect func() []Sample
+}
+
+func (r *Registry) NewGaugeFunc(name, help string, labels []string, collect func() []Sample) *GaugeFunc {
+	g := &GaugeFunc{desc: desc{name: name, help: help, kind: "gauge", labels: labels}, collect: collect}
+	r.register(g)
+	return g
+}
+
+func (g *GaugeFunc) write(w io.Writer) {
+	g.writeHeader(w)
+	samples := g.collect()
+	sort.Slice(samples, func(a, b int) bool { return key(samples[a].Labels) < key(samples[b].Labels) })
+	for _, sample := range samples {
+		fmt.Fprintf(w, "%s %s\n", g.series("", sample.Labels), formatValue(sample.Value))
+	}
+}
+
+// Histogram counts observations in buckets, with optional labels
+type Histogram struct {
+	desc
+	buckets []float64
+	mu      sync.Mutex
+	values  map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	labels []string
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// Default buckets for durations in seconds
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	h := &Histogram{
+		desc:    desc{name: name, help: help, kind: "histogram", labels: labels},
+		buckets: append([]float64{}, buckets...),
+		values:  make(map[string]*histogramSeries),
+	}
+	sort.Float64s(h.buckets)
+	r.register(h)
+	return h
+}
+
+// Observe adds a value to the series of the label values
+func (h *Histogram) Observe(value float64, labels ...string) {
+	if len(labels) != len(h.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d labels, got %d", h.name, len(h.labels), len(labels)))
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	k := key(labels)
+	s, exists := h.values[k]
+	if !exists {
+		s = &histogramSeries{labels: append([]string{}, labels...), counts: make([]uint64, len(h.buckets))}
+		h.values[k] = s
+	}
+	for i, bound := range h.buckets {
+		if value <= bound {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.sum += value
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.writeHeader(w)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	keys := make([]string, 0, len(h.values))
+	for k := range h.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		s := h.values[k]
+		for i, bound := range h.buckets {
+			fmt.Fprintf(w, "%s %d\n", h.series("_bucket", s.labels, "le", formatValue(bound)), s.counts[i])
+		}
+		fmt.Fprintf(w, "%s %d\n", h.series("_bucket", s.labels, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s %s\n", h.series("_sum", s.labels), formatValue(s.sum))
+		fmt.Fprintf(w, "%s %d\n", h.series("_count", s.labels), s.count)
+	}
+}
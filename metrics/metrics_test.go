@@ -0,0 +1,138 @@
+package metrics
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func export(r *Registry) string {
+	var output strings.Builder
+	r.Export(&output)
+	return output.String()
+}
+
+func TestCounter(t *testing.T) {
+	r := NewRegistry()
+	connects := r.NewCounter("test_connects_total", "Connections")
+	votes := r.NewCounter("test_votes_total", "Votes cast", "guild", "action")
+
+	connects.Inc()
+	votes.Inc("2", "MUTE")
+	votes.Inc("1", "MUTE")
+	votes.Inc("1", "MUTE")
+
+	want := `# HELP test_connects_total Connections
+# TYPE test_connects_total counter
+test_connects_total 1
+# HELP test_votes_total Votes cast
+# TYPE test_votes_total counter
+test_votes_total{guild="1",action="MUTE"} 2
+test_votes_total{guild="2",action="MUTE"} 1
+`
+	if got := export(r); got != want {
+		t.Errorf("export =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestCounterWithoutEventsIsExported(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("test_errors_total", "Errors")
+	r.NewCounter("test_labeled_total", "Labeled", "guild")
+
+	want := `# HELP test_errors_total Errors
+# TYPE test_errors_total counter
+test_errors_total 0
+# HELP test_labeled_total Labeled
+# TYPE test_labeled_total counter
+`
+	if got := export(r); got != want {
+		t.Errorf("export =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestLabelEscaping(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("test_total", "Test", "name").Inc("a \"quoted\\\" \nname\xff")
+
+	want := `test_total{name="a \"quoted\\\" \nname` + "�" + `"} 1`
+	if got := export(r); !strings.Contains(got, want+"\n") {
+		t.Errorf("export =\n%s\nwant the series\n%s", got, want)
+	}
+}
+
+func TestWrongLabelCount(t *testing.T) {
+	counter := NewRegistry().NewCounter("test_total", "Test", "guild")
+	defer func() {
+		if recover() == nil {
+			t.Error("no panic with a missing label")
+		}
+	}()
+	counter.Inc()
+}
+
+func TestGaugeFunc(t *testing.T) {
+	r := NewRegistry()
+	value := 1.0
+	r.NewGaugeFunc("test_muted", "Muted users", []string{"guild"}, func() []Sample {
+		return []Sample{{Labels: []string{"2"}, Value: value}, {Labels: []string{"1"}, Value: 0.5}}
+	})
+
+	value = 3
+	want := `# HELP test_muted Muted users
+# TYPE test_muted gauge
+test_muted{guild="1"} 0.5
+test_muted{guild="2"} 3
+`
+	if got := export(r); got != want {
+		t.Errorf("export =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	r := NewRegistry()
+	duration := r.NewHistogram("test_duration_seconds", "Duration", []float64{1, 0.1}, "command")
+
+	duration.Observe(0.05, "!mute")
+	duration.Observe(0.5, "!mute")
+	duration.Observe(2, "!mute")
+
+	// The buckets are sorted and cumulative
+	want := `# HELP test_duration_seconds Duration
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{command="!mute",le="0.1"} 1
+test_duration_seconds_bucket{command="!mute",le="1"} 2
+test_duration_seconds_bucket{command="!mute",le="+Inf"} 3
+test_duration_seconds_sum{command="!mute"} 2.55
+test_duration_seconds_count{command="!mute"} 3
+`
+	if got := export(r); got != want {
+		t.Errorf("export =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestFormatValue(t *testing.T) {
+	tests := map[float64]string{0: "0", 1e6: "1e+06", 0.25: "0.25", math.Inf(1): "+Inf", math.Inf(-1): "-Inf"}
+	for value, want := range tests {
+		if got := formatValue(value); got != want {
+			t.Errorf("formatValue(%v) = %q, want %q", value, got, want)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("test_total", "Test").Inc()
+
+	recorder := httptest.NewRecorder()
+	r.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if contentType := recorder.Header().Get("Content-Type"); contentType != "text/plain; version=0.0.4; charset=utf-8" {
+		t.Errorf("content type = %q", contentType)
+	}
+	if !strings.Contains(recorder.Body.String(), "test_total 1\n") {
+		t.Errorf("body = %q", recorder.Body.String())
+	}
+}